@@ -0,0 +1,336 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bestform/shmehashme/diff"
+	"github.com/bestform/shmehashme/highlight"
+	"github.com/bestform/shmehashme/lexer"
+	"github.com/bestform/shmehashme/lint"
+	"github.com/bestform/shmehashme/lsp"
+	"github.com/bestform/shmehashme/metrics"
+	"github.com/bestform/shmehashme/phptokens"
+	"github.com/bestform/shmehashme/repl"
+)
+
+func runLex(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("lex", stderr)
+	format := flags.String("format", "text", "Output format (text, json or php-tokens)")
+	tokenIDs := flags.String("token-ids", "", "JSON `file` with the token IDs for php-tokens as printed by phptokens/token_ids.php (default: token names)")
+	in := addInputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *format != "text" && *format != "json" && *format != "php-tokens" {
+		fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		return exitError
+	}
+
+	var ids phptokens.IDs
+	if *tokenIDs != "" {
+		f, err := os.Open(*tokenIDs)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error reading token IDs:", err)
+			return exitError
+		}
+		ids, err = phptokens.LoadIDs(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitError
+		}
+	}
+
+	inputs, err := in.resolveInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error reading input:", err)
+		return exitError
+	}
+
+	for _, res := range in.lexInputs(inputs, stdin, stderr) {
+		if res.Err != nil {
+			fmt.Fprintln(stderr, "Error lexing input:", res.Err)
+			return exitError
+		}
+
+		switch *format {
+		case "text":
+			if len(inputs) > 1 {
+				fmt.Fprintf(stdout, "==> %s <==\n", res.Path)
+			}
+			fmt.Fprint(stdout, lexer.PrettyPrint(res.Tokens))
+		case "json":
+			o, err := lexer.PrintFileJSON(res.Path, res.Tokens)
+			if err != nil {
+				fmt.Fprintln(stderr, "Error encoding tokens:", err)
+				return exitError
+			}
+			fmt.Fprint(stdout, o)
+		case "php-tokens":
+			o, err := phptokens.PrintJSON(phptokens.Convert(res.Source), ids)
+			if err != nil {
+				fmt.Fprintln(stderr, "Error encoding tokens:", err)
+				return exitError
+			}
+			fmt.Fprint(stdout, o)
+		}
+	}
+
+	return exitOK
+}
+
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("check", stderr)
+	config := flags.String("config", "", "JSON `file` to configure the lint rules with")
+	fix := flags.Bool("fix", false, "Apply suggested fixes to the files")
+	showDiff := flags.Bool("diff", false, "Print the suggested fixes as a unified diff instead of applying them")
+	in := addInputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *fix && *showDiff {
+		fmt.Fprintln(stderr, "-fix and -diff cannot be used together")
+		return exitError
+	}
+
+	var cfg *lint.Config
+	if *config != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(*config); err != nil {
+			fmt.Fprintln(stderr, "Error reading configuration:", err)
+			return exitError
+		}
+	}
+
+	inputs, err := in.resolveInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error reading input:", err)
+		return exitError
+	}
+
+	code := exitOK
+	for _, res := range in.lexInputs(inputs, stdin, stderr) {
+		if res.Err != nil {
+			fmt.Fprintln(stderr, "Error lexing input:", res.Err)
+			return exitError
+		}
+
+		file := &lint.File{Path: res.Path, Source: res.Source, Tokens: res.Tokens}
+		if !*fix && !*showDiff {
+			diagnostics, err := lint.Lint(file, cfg)
+			if err != nil {
+				fmt.Fprintln(stderr, "Error checking input:", err)
+				return exitError
+			}
+			code = reportDiagnostics(stdout, diagnostics, code)
+			continue
+		}
+
+		fixed, diagnostics, err := lint.Fix(file, cfg)
+		if err != nil {
+			fmt.Fprintln(stderr, "Error checking input:", err)
+			return exitError
+		}
+		if *showDiff {
+			if d := diff.Unified(res.Path, res.Path+" (fixed)", file.Source, fixed.Source); d != "" {
+				fmt.Fprint(stdout, d)
+				code = exitFindings
+			}
+			continue
+		}
+		if fixed.Source != file.Source {
+			if res.Path == stdinName {
+				fmt.Fprintln(stderr, "Cannot fix stdin in place, use -diff")
+				return exitError
+			}
+			if err := writeFile(res.Path, fixed.Source); err != nil {
+				fmt.Fprintln(stderr, "Error writing fixed file:", err)
+				return exitError
+			}
+		}
+		code = reportDiagnostics(stdout, diagnostics, code)
+	}
+
+	return code
+}
+
+// reportDiagnostics prints the diagnostics and returns the exit code to use
+// if code is the exit code so far
+func reportDiagnostics(w io.Writer, diagnostics []lint.Diagnostic, code int) int {
+	for _, d := range diagnostics {
+		fmt.Fprintln(w, d)
+		if d.Severity >= lint.Warning {
+			code = exitFindings
+		}
+	}
+
+	return code
+}
+
+// writeFile replaces the content of an existing file, keeping its permissions
+func writeFile(path, content string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(content), info.Mode())
+}
+
+func runMetrics(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("metrics", stderr)
+	format := flags.String("format", "text", "Output format (text, csv or json)")
+	var t metrics.Thresholds
+	flags.IntVar(&t.Cyclomatic, "max-cyclomatic", 0, "Report functions with a higher cyclomatic complexity")
+	flags.IntVar(&t.Cognitive, "max-cognitive", 0, "Report functions with a higher cognitive complexity")
+	flags.IntVar(&t.Nesting, "max-nesting", 0, "Report functions nested deeper")
+	flags.IntVar(&t.Params, "max-params", 0, "Report functions with more parameters")
+	flags.IntVar(&t.Lines, "max-lines", 0, "Report functions with more lines")
+	flags.IntVar(&t.Methods, "max-methods", 0, "Report classes with more methods")
+	in := addInputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	write := map[string]func(io.Writer, []metrics.File) error{
+		"text": metrics.WriteText,
+		"csv":  metrics.WriteCSV,
+		"json": metrics.WriteJSON,
+	}[*format]
+	if write == nil {
+		fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		return exitError
+	}
+
+	inputs, err := in.resolveInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error reading input:", err)
+		return exitError
+	}
+
+	var files []metrics.File
+	for _, res := range in.lexInputs(inputs, stdin, stderr) {
+		if res.Err != nil {
+			fmt.Fprintln(stderr, "Error lexing input:", res.Err)
+			return exitError
+		}
+		files = append(files, metrics.AnalyzeTokens(res.Path, res.Source, res.Tokens))
+	}
+
+	if err := write(stdout, files); err != nil {
+		fmt.Fprintln(stderr, "Error writing metrics:", err)
+		return exitError
+	}
+
+	code := exitOK
+	for _, f := range files {
+		for _, d := range f.Check(t) {
+			fmt.Fprintln(stderr, d)
+			code = exitFindings
+		}
+	}
+
+	return code
+}
+
+func runHighlight(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("highlight", stderr)
+	format := flags.String("format", "ansi", "Output format (ansi, html, svg or css for the stylesheet of html)")
+	lineNumbers := flags.Bool("line-numbers", false, "Show line numbers")
+	in := addInputFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *format == "css" {
+		fmt.Fprint(stdout, highlight.CSS(nil))
+		return exitOK
+	}
+	render := map[string]func(io.Writer, string, []lexer.Token, highlight.Options) error{
+		"ansi": highlight.ANSI,
+		"html": highlight.HTML,
+		"svg":  highlight.SVG,
+	}[*format]
+	if render == nil {
+		fmt.Fprintf(stderr, "Unknown format %q\n", *format)
+		return exitError
+	}
+
+	inputs, err := in.resolveInputs(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "Error reading input:", err)
+		return exitError
+	}
+
+	for _, res := range in.lexInputs(inputs, stdin, stderr) {
+		if res.Err != nil {
+			fmt.Fprintln(stderr, "Error lexing input:", res.Err)
+			return exitError
+		}
+		if err := render(stdout, res.Source, res.Tokens, highlight.Options{LineNumbers: *lineNumbers}); err != nil {
+			fmt.Fprintln(stderr, "Error writing output:", err)
+			return exitError
+		}
+	}
+
+	return exitOK
+}
+
+func runRepl(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("repl", stderr)
+	history := flags.String("history", defaultHistoryFile(), "File to keep the input history in (empty disables history)")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	fmt.Fprintln(stdout, "Why hello there! This a REPL for the shmehashme PHP lexer")
+	fmt.Fprintln(stdout, "Feel free to type in commands (:help lists the REPL commands)")
+	if err := repl.Run(stdin, stdout, repl.Options{HistoryFile: *history}); err != nil {
+		fmt.Fprintln(stderr, "Error running REPL:", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func runLsp(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("lsp", stderr)
+	config := flags.String("config", "", "JSON `file` to configure the lint rules with")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	var cfg *lint.Config
+	if *config != "" {
+		var err error
+		if cfg, err = lint.LoadConfig(*config); err != nil {
+			fmt.Fprintln(stderr, "Error reading configuration:", err)
+			return exitError
+		}
+	}
+
+	if err := lsp.NewServer(cfg).Serve(stdin, stdout); err != nil {
+		fmt.Fprintln(stderr, "Error running language server:", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".shmehashme_history")
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
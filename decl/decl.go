@@ -0,0 +1,162 @@
+// Package decl finds the classes, interfaces, traits, functions and methods
+// declared in a token stream.
+//
+// There is no parser for PHP yet, so a declaration is its keyword up to the
+// brace that closes its body, or up to the semicolon ending a declaration
+// without body like an abstract method.
+package decl
+
+import (
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Kind tells what a declaration declares
+type Kind int
+
+// declaration kinds
+const (
+	Class Kind = iota + 1
+	Interface
+	Trait
+	Function // a function or a closure
+	Method
+)
+
+// Declaration is a declaration in a token stream. All fields but Kind and
+// Name are indices of tokens or declarations.
+type Declaration struct {
+	Kind      Kind
+	Name      string // empty for closures and anonymous classes
+	Parent    int    // the class declaring a method, -1 for other declarations
+	First     int    // the first modifier, or the keyword if there is none
+	Keyword   int    // the class, interface, trait or function keyword
+	NameToken int    // -1 for closures and anonymous classes
+	Params    int    // the opening parenthesis of the parameters, -1 for classes or if it is missing
+	Body      int    // the opening brace of the body, -1 if there is none
+	End       int    // the closing brace of the body or the semicolon ending the declaration, -1 if it is missing
+	Doc       int    // the doc comment in front of the declaration, -1 if there is none
+}
+
+// Anonymous reports whether the declaration is a closure or an anonymous class
+func (d Declaration) Anonymous() bool {
+	return d.NameToken < 0
+}
+
+// modifiers may precede the keyword of a declaration
+var modifiers = map[string]bool{
+	"abstract": true, "final": true, "public": true, "protected": true, "private": true, "static": true,
+}
+
+// Find returns the declarations among the tokens of src in the order of
+// their keywords
+func Find(src string, tokens []lexer.Token) []Declaration {
+	var declarations []Declaration
+	var stack []int // for each open brace the declaration whose body it opens, -1 for other braces
+	pending := -1   // declaration waiting for its body
+
+	for i, tok := range tokens {
+		switch tok.Type {
+		case lexer.LBRACE:
+			stack = append(stack, pending)
+			if pending >= 0 {
+				declarations[pending].Body = i
+			}
+			pending = -1
+			continue
+		case lexer.RBRACE:
+			if len(stack) == 0 {
+				continue
+			}
+			opened := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if opened >= 0 {
+				declarations[opened].End = i
+			}
+			continue
+		case lexer.SEMICOLON:
+			// abstract and interface methods have no body
+			if pending >= 0 {
+				declarations[pending].End = i
+				pending = -1
+			}
+			continue
+		}
+
+		kind := kindAt(tokens, i)
+		if kind == 0 {
+			continue
+		}
+
+		d := Declaration{Kind: kind, Parent: -1, First: i, Keyword: i, NameToken: -1, Params: -1, Body: -1, End: -1, Doc: -1}
+		next := i + 1
+		if kind == Function && next < len(tokens) && tokens[next].Type == lexer.REFERENCE {
+			next++
+		}
+		if next < len(tokens) && tokens[next].Type == lexer.IDENT {
+			d.NameToken = next
+			d.Name = tokens[next].Literal
+			next++
+		}
+		if d.NameToken < 0 && (kind == Interface || kind == Trait) {
+			// only classes and functions can be anonymous
+			continue
+		}
+		if kind == Function && next < len(tokens) && tokens[next].Type == lexer.LPAREN {
+			d.Params = next
+		}
+
+		if kind == Function && len(stack) > 0 {
+			// a function directly in the body of a class is a method
+			parent := stack[len(stack)-1]
+			if parent >= 0 && declarations[parent].Kind != Function && declarations[parent].Kind != Method {
+				d.Kind = Method
+				d.Parent = parent
+			}
+		}
+
+		for d.First > 0 && isModifier(tokens[d.First-1]) {
+			d.First--
+		}
+		if d.First > 0 {
+			prev := tokens[d.First-1]
+			text := src[prev.Offset:prev.End]
+			if prev.Type == lexer.COMMENT && strings.HasPrefix(text, "/**") && text != "/**/" {
+				d.Doc = d.First - 1
+			}
+		}
+
+		declarations = append(declarations, d)
+		pending = len(declarations) - 1
+	}
+
+	return declarations
+}
+
+// kindAt returns the kind of declaration if the token at i is the keyword
+// of one and 0 otherwise
+func kindAt(tokens []lexer.Token, i int) Kind {
+	tok := tokens[i]
+	switch {
+	case tok.Type == lexer.FUNCTION:
+		return Function
+	case tok.Type == lexer.CLASS:
+		if i > 0 && tokens[i-1].Type == lexer.COLON {
+			// Foo::class
+			return 0
+		}
+		return Class
+	case tok.Type == lexer.IDENT && strings.EqualFold(tok.Literal, "interface"):
+		return Interface
+	case tok.Type == lexer.IDENT && strings.EqualFold(tok.Literal, "trait"):
+		return Trait
+	}
+
+	return 0
+}
+
+func isModifier(tok lexer.Token) bool {
+	lower := strings.ToLower(tok.Literal)
+	return modifiers[lower] && (tok.Type == lexer.IDENT || tok.Type == lexer.LookupIdent(lower))
+}
@@ -0,0 +1,71 @@
+package decl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+func TestFind(t *testing.T) {
+
+	src := `<?php
+/** A greeter */
+interface Greeter { public function greet($name); }
+final class Foo extends Bar implements Greeter {
+    /** Greets. */
+    public static function &greet($name) {
+        return array_map(function ($n) { return $n; }, [$name]);
+    }
+}
+trait T { abstract protected function t(); }
+$o = new class { function run() {} };
+echo Foo::class;
+function helper() {}
+`
+	l, err := lexer.New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal("error creating lexer", err)
+	}
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	expected := []struct {
+		kind   Kind
+		name   string
+		parent int
+		first  string // literal of the first token
+		body   bool
+		end    lexer.TokenType
+		doc    bool
+	}{
+		{Interface, "Greeter", -1, "interface", true, lexer.RBRACE, true},
+		{Method, "greet", 0, "public", false, lexer.SEMICOLON, false},
+		{Class, "Foo", -1, "final", true, lexer.RBRACE, false},
+		{Method, "greet", 2, "public", true, lexer.RBRACE, true},
+		{Function, "", -1, "function", true, lexer.RBRACE, false},
+		{Trait, "T", -1, "trait", true, lexer.RBRACE, false},
+		{Method, "t", 5, "abstract", false, lexer.SEMICOLON, false},
+		{Class, "", -1, "class", true, lexer.RBRACE, false},
+		{Method, "run", 7, "function", true, lexer.RBRACE, false},
+		{Function, "helper", -1, "function", true, lexer.RBRACE, false},
+	}
+
+	declarations := Find(src, tokens)
+	if len(declarations) != len(expected) {
+		t.Fatalf("expected %d declarations, got %d: %+v", len(expected), len(declarations), declarations)
+	}
+	for i, e := range expected {
+		d := declarations[i]
+		if d.Kind != e.kind || d.Name != e.name || d.Parent != e.parent || tokens[d.First].Literal != e.first ||
+			(d.Body >= 0) != e.body || d.End < 0 || tokens[d.End].Type != e.end || (d.Doc >= 0) != e.doc {
+			t.Errorf("declarations[%d] - expected %+v, got %+v", i, e, d)
+		}
+		if d.Anonymous() != (e.name == "") {
+			t.Errorf("declarations[%d] - expected anonymous to be %v", i, e.name == "")
+		}
+	}
+
+}
@@ -0,0 +1,256 @@
+// Package diff compares texts line by line and prints the result as a unified diff
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// opKind tells whether a line was kept, deleted or inserted
+type opKind byte
+
+const (
+	keep   opKind = ' '
+	remove opKind = '-'
+	insert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns the differences between a and b in unified diff format,
+// or an empty string if they are equal
+func Unified(nameA, nameB, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	ops := lineOps(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == keep {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk until more than 2*contextLines unchanged lines follow
+		first := start - contextLines
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != keep {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == keep {
+				run++
+			}
+			if run == len(ops) || run-end > 2*contextLines {
+				break
+			}
+			end = run
+		}
+		last := end + contextLines
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		writeHunk(&out, ops, first, last)
+		start = last
+	}
+
+	return out.String()
+}
+
+func writeHunk(out *bytes.Buffer, ops []op, first, last int) {
+	lineA, lineB := 1, 1
+	for _, o := range ops[:first] {
+		if o.kind != insert {
+			lineA++
+		}
+		if o.kind != remove {
+			lineB++
+		}
+	}
+
+	var countA, countB int
+	for _, o := range ops[first:last] {
+		if o.kind != insert {
+			countA++
+		}
+		if o.kind != remove {
+			countB++
+		}
+	}
+	if countA == 0 {
+		lineA--
+	}
+	if countB == 0 {
+		lineB--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", lineA, countA, lineB, countB)
+	for _, o := range ops[first:last] {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(strings.TrimSuffix(o.line, "\n"))
+		out.WriteByte('\n')
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\\ No newline at end of file\n")
+		}
+	}
+}
+
+// splitLines splits s after every newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps computes a shortest edit script from a to b with the linear space
+// variant of the algorithm described in "An O(ND) Difference Algorithm and Its
+// Variations" by Eugene W. Myers: the middle snake of a shortest edit path
+// splits the problem in two halves that are solved recursively
+func lineOps(a, b []string) []op {
+	var ops []op
+	return appendOps(ops, a, b)
+}
+
+// appendOps appends the edit script from a to b to ops
+func appendOps(ops []op, a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, op{keep, a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middleSnake(a, b); ok {
+		ops = appendOps(ops, a[:x], b[:y])
+		ops = appendOps(ops, a[x:], b[y:])
+	} else {
+		for _, line := range a {
+			ops = append(ops, op{remove, line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{insert, line})
+		}
+	}
+
+	for _, line := range common {
+		ops = append(ops, op{keep, line})
+	}
+
+	return ops
+}
+
+// middleSnake searches a shortest edit path from a to b forwards and
+// backwards at the same time and returns the point where both searches meet.
+// It returns false if a or b is empty or they have no line in common, then
+// all lines of a are removed and all lines of b inserted.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)  // furthest x on each diagonal k = x - y from the start
+	backward := make([]int, 2*maxD+2) // furthest x on each diagonal from the end
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	// the searches meet on a forward pass if the difference in length is odd
+	delta := n - m
+	odd := delta%2 != 0
+
+	// diagonals running off the edit graph are not searched again
+	var kStart, kEnd, rStart, rEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				kEnd += 2
+			case y > m:
+				kStart += 2
+			case odd:
+				r := offset + delta - k
+				if r >= 0 && r < len(backward) && backward[r] != -1 && x >= n-backward[r] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				f := offset + delta - k
+				if f >= 0 && f < len(forward) && forward[f] != -1 && forward[f] >= n-x {
+					fx := forward[f]
+					return fx, fx - (f - offset), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
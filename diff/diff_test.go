@@ -0,0 +1,139 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"",
+			"a\n",
+			"--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			"a\nb",
+			"a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n",
+			"1\nzwei\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n16\n",
+			"--- old\n+++ new\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+zwei\n 3\n 4\n 5\n" +
+				"@@ -12,5 +12,4 @@\n 12\n 13\n 14\n-15\n 16\n",
+		},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n",
+			"1\nzwei\n3\n4\n5\n6\n7\nacht\n",
+			"--- old\n+++ new\n" +
+				"@@ -1,8 +1,8 @@\n 1\n-2\n+zwei\n 3\n 4\n 5\n 6\n 7\n-8\n+acht\n",
+		},
+	}
+
+	for i, tt := range tests {
+		actual := Unified("old", "new", tt.a, tt.b)
+		if actual != tt.expected {
+			t.Fatalf("tests[%d] - diff wrong:\nEXPECTED:\n%s\nACTUAL:\n%s", i, tt.expected, actual)
+		}
+	}
+
+}
+
+// lcs returns the length of the longest common subsequence of a and b
+func lcs(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestLineOps(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rnd.Intn(20))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		ops := lineOps(a, b)
+
+		var gotA, gotB []string
+		changes := 0
+		for _, o := range ops {
+			if o.kind != insert {
+				gotA = append(gotA, o.line)
+			}
+			if o.kind != remove {
+				gotB = append(gotB, o.line)
+			}
+			if o.kind != keep {
+				changes++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("%q -> %q - edit script does not rebuild the inputs: %v", a, b, ops)
+		}
+		if shortest := len(a) + len(b) - 2*lcs(a, b); changes != shortest {
+			t.Fatalf("%q -> %q - expected %d changes, got %d: %v", a, b, shortest, changes, ops)
+		}
+	}
+
+}
+
+func TestLineOpsMemory(t *testing.T) {
+
+	// every other line changes, which used to need memory quadratic in the
+	// number of changes
+	for _, size := range []int{1500, 6000} {
+		var a, b strings.Builder
+		for i := 0; i < size; i++ {
+			fmt.Fprintf(&a, "same %d\nold %d\n", i, i)
+			fmt.Fprintf(&b, "same %d\nnew %d\n", i, i)
+		}
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		ops := lineOps(splitLines(a.String()), splitLines(b.String()))
+		runtime.ReadMemStats(&after)
+
+		if len(ops) != 3*size {
+			t.Fatalf("%d changes - expected %d operations, got %d", size, 3*size, len(ops))
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+			t.Fatalf("%d changes - allocated %d bytes", size, allocated)
+		}
+	}
+
+}
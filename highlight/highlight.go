@@ -0,0 +1,176 @@
+// Package highlight renders PHP source code with syntax highlighting based on its tokens
+package highlight
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Category groups token types that are highlighted the same way
+type Category int
+
+const (
+	// Plain is used for identifiers, delimiters, whitespace and everything else
+	Plain Category = iota
+	// Keyword is used for keywords and the PHP tag
+	Keyword
+	// Variable is used for variables
+	Variable
+	// String is used for quoted strings
+	String
+	// Number is used for integers and floats
+	Number
+	// Comment is used for comments
+	Comment
+	// Operator is used for arithmetic, comparison, logical and assignment operators
+	Operator
+)
+
+var categoryNames = []string{"plain", "keyword", "variable", "string", "number", "comment", "operator"}
+
+func (c Category) String() string {
+	if c >= 0 && int(c) < len(categoryNames) {
+		return categoryNames[c]
+	}
+	return fmt.Sprintf("Category(%d)", int(c))
+}
+
+var categories = map[lexer.TokenType]Category{
+	lexer.FUNCTION:   Keyword,
+	lexer.RETURN:     Keyword,
+	lexer.PUBLIC:     Keyword,
+	lexer.PRIVATE:    Keyword,
+	lexer.STATIC:     Keyword,
+	lexer.PROTECTED:  Keyword,
+	lexer.PHPTAG:     Keyword,
+	lexer.CLASS:      Keyword,
+	lexer.IMPLEMENTS: Keyword,
+	lexer.EXTENDS:    Keyword,
+	lexer.IF:         Keyword,
+	lexer.TRUE:       Keyword,
+	lexer.FALSE:      Keyword,
+	lexer.USE:        Keyword,
+	lexer.FOR:        Keyword,
+	lexer.FOREACH:    Keyword,
+	lexer.AS:         Keyword,
+
+	lexer.VAR: Variable,
+
+	lexer.DOUBLEQUOTEDSTRING: String,
+	lexer.SINGLEQUOTEDSTRING: String,
+
+	lexer.INT:   Number,
+	lexer.FLOAT: Number,
+
+	lexer.COMMENT: Comment,
+
+	lexer.ASSIGN:             Operator,
+	lexer.REFERENCE:          Operator,
+	lexer.PLUS:               Operator,
+	lexer.MINUS:              Operator,
+	lexer.MULTIPLY:           Operator,
+	lexer.DIVIDE:             Operator,
+	lexer.EQUALS:             Operator,
+	lexer.IDENTITY:           Operator,
+	lexer.LESSTHAN:           Operator,
+	lexer.GREATERTHAN:        Operator,
+	lexer.LESSTHANOREQUAL:    Operator,
+	lexer.GREATERTHANOREQUAL: Operator,
+	lexer.OR:                 Operator,
+	lexer.AND:                Operator,
+	lexer.NOT:                Operator,
+	lexer.INC:                Operator,
+	lexer.DEC:                Operator,
+	lexer.QUESTIONMARK:       Operator,
+	lexer.COLON:              Operator,
+	lexer.DOT:                Operator,
+	lexer.DOUBLEARROW:        Operator,
+	lexer.ARROW:              Operator,
+	lexer.SPACESHIP:          Operator,
+}
+
+// CategoryOf returns the category a token type is highlighted with
+func CategoryOf(t lexer.TokenType) Category {
+	return categories[t]
+}
+
+// Style describes how one category is displayed
+type Style struct {
+	ANSI  string // SGR parameters for terminals, e.g. "1;34" for bold blue
+	Color string // CSS color for HTML and SVG, e.g. "#0000ff"
+	Bold  bool   // used for HTML and SVG
+}
+
+// Theme maps categories to styles. Categories without a style are not highlighted.
+type Theme map[Category]Style
+
+// DefaultTheme is used if no theme is given
+var DefaultTheme = Theme{
+	Keyword:  {ANSI: "1;34", Color: "#0033b3", Bold: true},
+	Variable: {ANSI: "35", Color: "#871094"},
+	String:   {ANSI: "32", Color: "#067d17"},
+	Number:   {ANSI: "36", Color: "#1750eb"},
+	Comment:  {ANSI: "90", Color: "#8c8c8c"},
+	Operator: {ANSI: "33", Color: "#a35a00"},
+}
+
+// Options control the output of the renderers
+type Options struct {
+	Theme       Theme // DefaultTheme if nil
+	LineNumbers bool
+}
+
+func (o Options) theme() Theme {
+	if o.Theme == nil {
+		return DefaultTheme
+	}
+	return o.Theme
+}
+
+// segment is a piece of source code on one line with a single category
+type segment struct {
+	text     string
+	category Category
+}
+
+// lines splits the source into lines of highlighted segments without the line endings.
+// The tokens must come from lexing src and carry their offsets.
+func lines(src string, tokens []lexer.Token) [][]segment {
+	var segments []segment
+	pos := 0
+	for _, tok := range tokens {
+		if tok.Offset < pos || tok.End > len(src) {
+			continue
+		}
+		if tok.Offset > pos {
+			segments = append(segments, segment{src[pos:tok.Offset], Plain})
+		}
+		if tok.End > tok.Offset {
+			segments = append(segments, segment{src[tok.Offset:tok.End], CategoryOf(tok.Type)})
+		}
+		pos = tok.End
+	}
+	if pos < len(src) {
+		segments = append(segments, segment{src[pos:], Plain})
+	}
+
+	result := [][]segment{nil}
+	for _, s := range segments {
+		parts := strings.Split(s.text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				result = append(result, nil)
+			}
+			if part = strings.TrimSuffix(part, "\r"); part != "" {
+				result[len(result)-1] = append(result[len(result)-1], segment{part, s.category})
+			}
+		}
+	}
+	if strings.HasSuffix(src, "\n") {
+		result = result[:len(result)-1]
+	}
+
+	return result
+}
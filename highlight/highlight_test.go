@@ -0,0 +1,174 @@
+package highlight
+
+import (
+	"bytes"
+	"html"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+const source = "<?php\n$a = \"<b>\" . 1; // hi\n/* x\ny */\n"
+
+func tokenize(t *testing.T, src string) []lexer.Token {
+	l, err := lexer.New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal("error creating lexer", err)
+	}
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+func TestRenderers(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		render   func(w *bytes.Buffer, src string, tokens []lexer.Token, opts Options) error
+		opts     Options
+		expected string
+	}{
+		{
+			"ansi",
+			func(w *bytes.Buffer, src string, tokens []lexer.Token, opts Options) error {
+				return ANSI(w, src, tokens, opts)
+			},
+			Options{},
+			"\x1b[1;34m<?php\x1b[0m\n" +
+				"\x1b[35m$a\x1b[0m \x1b[33m=\x1b[0m \x1b[32m\"<b>\"\x1b[0m \x1b[33m.\x1b[0m \x1b[36m1\x1b[0m; \x1b[90m// hi\x1b[0m\n" +
+				"\x1b[90m/* x\x1b[0m\n" +
+				"\x1b[90my */\x1b[0m\n",
+		},
+		{
+			"ansi with line numbers and theme",
+			func(w *bytes.Buffer, src string, tokens []lexer.Token, opts Options) error {
+				return ANSI(w, src, tokens, opts)
+			},
+			Options{Theme: Theme{Variable: {ANSI: "1"}}, LineNumbers: true},
+			"\x1b[90m1\x1b[0m <?php\n" +
+				"\x1b[90m2\x1b[0m \x1b[1m$a\x1b[0m = \"<b>\" . 1; // hi\n" +
+				"\x1b[90m3\x1b[0m /* x\n" +
+				"\x1b[90m4\x1b[0m y */\n",
+		},
+		{
+			"html",
+			func(w *bytes.Buffer, src string, tokens []lexer.Token, opts Options) error {
+				return HTML(w, src, tokens, opts)
+			},
+			Options{LineNumbers: true},
+			`<pre class="shmehashme"><code><span class="line-number">1</span> <span class="keyword">&lt;?php</span>` + "\n" +
+				`<span class="line-number">2</span> <span class="variable">$a</span> <span class="operator">=</span> ` +
+				`<span class="string">&#34;&lt;b&gt;&#34;</span> <span class="operator">.</span> <span class="number">1</span>; ` +
+				`<span class="comment">// hi</span>` + "\n" +
+				`<span class="line-number">3</span> <span class="comment">/* x</span>` + "\n" +
+				`<span class="line-number">4</span> <span class="comment">y */</span>` + "\n" +
+				"</code></pre>\n",
+		},
+		{
+			"svg",
+			func(w *bytes.Buffer, src string, tokens []lexer.Token, opts Options) error {
+				return SVG(w, src, tokens, opts)
+			},
+			Options{Theme: Theme{Keyword: {Color: "#0000ff", Bold: true}}},
+			`<svg xmlns="http://www.w3.org/2000/svg" width="196.4" height="100" viewBox="0 0 196.4 100">` + "\n" +
+				`<rect width="100%" height="100%" fill="#ffffff"/>` + "\n" +
+				`<g font-family="monospace" font-size="14" xml:space="preserve">` + "\n" +
+				`<text x="10" y="25"><tspan fill="#0000ff" font-weight="bold">&lt;?php</tspan></text>` + "\n" +
+				`<text x="10" y="45">$a = &#34;&lt;b&gt;&#34; . 1; // hi</text>` + "\n" +
+				`<text x="10" y="65">/* x</text>` + "\n" +
+				`<text x="10" y="85">y */</text>` + "\n" +
+				"</g>\n</svg>\n",
+		},
+	}
+
+	tokens := tokenize(t, source)
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := tt.render(&b, source, tokens, tt.opts); err != nil {
+			t.Fatal("error rendering", err)
+		}
+		if b.String() != tt.expected {
+			t.Fatalf("%v - output wrong:\nEXPECTED:\n%q\nACTUAL:\n%q", tt.name, tt.expected, b.String())
+		}
+	}
+
+}
+
+func TestCSS(t *testing.T) {
+
+	expected := ".shmehashme .line-number { color: #999999; user-select: none; }\n" +
+		".shmehashme .keyword { color: #0000ff; font-weight: bold; }\n" +
+		".shmehashme .comment { color: #00ff00; }\n"
+
+	css := CSS(Theme{Comment: {Color: "#00ff00"}, Keyword: {Color: "#0000ff", Bold: true}, String: {ANSI: "32"}})
+	if css != expected {
+		t.Fatalf("CSS wrong:\nEXPECTED:\n%s\nACTUAL:\n%s", expected, css)
+	}
+
+}
+
+func TestCategoryString(t *testing.T) {
+
+	tests := []struct {
+		category Category
+		expected string
+	}{
+		{Plain, "plain"},
+		{Operator, "operator"},
+		{Operator + 1, "Category(7)"},
+		{-1, "Category(-1)"},
+	}
+
+	for _, tt := range tests {
+		if s := tt.category.String(); s != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, s)
+		}
+	}
+
+}
+
+// the highlighted output must contain the source code unchanged
+func TestRoundTrip(t *testing.T) {
+
+	fixtures, err := filepath.Glob("../lexer/fixtures/*.php")
+	if err != nil {
+		t.Fatal("error listing fixtures", err)
+	}
+
+	escapes := regexp.MustCompile("\x1b\\[[0-9;]*m")
+	tags := regexp.MustCompile("<[^>]*>")
+
+	for _, fixture := range fixtures {
+		input, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal("error reading fixture", err)
+		}
+		src := string(input)
+		if !strings.HasSuffix(src, "\n") {
+			src += "\n"
+		}
+		tokens := tokenize(t, src)
+
+		var ansi, htm bytes.Buffer
+		if err := ANSI(&ansi, src, tokens, Options{}); err != nil {
+			t.Fatal("error rendering", err)
+		}
+		if err := HTML(&htm, src, tokens, Options{}); err != nil {
+			t.Fatal("error rendering", err)
+		}
+
+		if plain := escapes.ReplaceAllString(ansi.String(), ""); plain != src {
+			t.Fatalf("%v - ANSI output does not contain the source:\n%q", fixture, plain)
+		}
+		if plain := html.UnescapeString(tags.ReplaceAllString(htm.String(), "")); plain != src+"\n" {
+			t.Fatalf("%v - HTML output does not contain the source:\n%q", fixture, plain)
+		}
+	}
+
+}
@@ -0,0 +1,146 @@
+package highlight
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// ANSI writes the source with ANSI escape sequences for terminals
+func ANSI(w io.Writer, src string, tokens []lexer.Token, opts Options) error {
+	theme := opts.theme()
+	ls := lines(src, tokens)
+	b := bufio.NewWriter(w)
+
+	for i, line := range ls {
+		if opts.LineNumbers {
+			fmt.Fprintf(b, "\x1b[90m%*d\x1b[0m ", numberWidth(len(ls)), i+1)
+		}
+		for _, s := range line {
+			style, ok := theme[s.category]
+			if !ok || style.ANSI == "" {
+				b.WriteString(s.text)
+				continue
+			}
+			fmt.Fprintf(b, "\x1b[%sm%s\x1b[0m", style.ANSI, s.text)
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.Flush()
+}
+
+// HTML writes the source as a <pre> element with a <span> and CSS class
+// for every highlighted token. Use CSS to get the matching stylesheet.
+func HTML(w io.Writer, src string, tokens []lexer.Token, opts Options) error {
+	ls := lines(src, tokens)
+	b := bufio.NewWriter(w)
+
+	b.WriteString(`<pre class="shmehashme"><code>`)
+	for i, line := range ls {
+		if opts.LineNumbers {
+			fmt.Fprintf(b, `<span class="line-number">%*d</span> `, numberWidth(len(ls)), i+1)
+		}
+		for _, s := range line {
+			if s.category == Plain {
+				b.WriteString(html.EscapeString(s.text))
+				continue
+			}
+			fmt.Fprintf(b, `<span class="%s">%s</span>`, s.category, html.EscapeString(s.text))
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("</code></pre>\n")
+
+	return b.Flush()
+}
+
+// CSS returns the stylesheet for the output of HTML
+func CSS(theme Theme) string {
+	if theme == nil {
+		theme = DefaultTheme
+	}
+
+	var b strings.Builder
+	b.WriteString(".shmehashme .line-number { color: #999999; user-select: none; }\n")
+	for c := range categoryNames {
+		style, ok := theme[Category(c)]
+		if !ok || style.Color == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ".shmehashme .%s { color: %s;", Category(c), style.Color)
+		if style.Bold {
+			b.WriteString(" font-weight: bold;")
+		}
+		b.WriteString(" }\n")
+	}
+
+	return b.String()
+}
+
+const (
+	svgFontSize   = 14
+	svgLineHeight = 20
+	svgCharWidth  = 8.4 // of a monospace font at svgFontSize
+	svgPadding    = 10
+)
+
+// SVG writes the source as an SVG image using a monospace font
+func SVG(w io.Writer, src string, tokens []lexer.Token, opts Options) error {
+	theme := opts.theme()
+	ls := lines(src, tokens)
+	b := bufio.NewWriter(w)
+
+	prefix := 0
+	if opts.LineNumbers {
+		prefix = numberWidth(len(ls)) + 1
+	}
+	columns := 0
+	for _, line := range ls {
+		n := prefix
+		for _, s := range line {
+			n += utf8.RuneCountInString(s.text)
+		}
+		if n > columns {
+			columns = n
+		}
+	}
+	width := float64(columns)*svgCharWidth + 2*svgPadding
+	height := len(ls)*svgLineHeight + 2*svgPadding
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%d" viewBox="0 0 %g %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(b, `<g font-family="monospace" font-size="%d" xml:space="preserve">`+"\n", svgFontSize)
+	for i, line := range ls {
+		fmt.Fprintf(b, `<text x="%d" y="%d">`, svgPadding, svgPadding+(i+1)*svgLineHeight-5)
+		if opts.LineNumbers {
+			fmt.Fprintf(b, `<tspan fill="#999999">%*d </tspan>`, numberWidth(len(ls)), i+1)
+		}
+		for _, s := range line {
+			style, ok := theme[s.category]
+			if !ok || style.Color == "" {
+				b.WriteString(html.EscapeString(s.text))
+				continue
+			}
+			weight := ""
+			if style.Bold {
+				weight = ` font-weight="bold"`
+			}
+			fmt.Fprintf(b, `<tspan fill="%s"%s>%s</tspan>`, style.Color, weight, html.EscapeString(s.text))
+		}
+		b.WriteString("</text>\n")
+	}
+	b.WriteString("</g>\n</svg>\n")
+
+	return b.Flush()
+}
+
+// numberWidth returns the number of digits needed for line numbers up to n
+func numberWidth(n int) int {
+	return len(fmt.Sprint(n))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bestform/shmehashme/scan"
+)
+
+// stdinName stands for stdin in the list of inputs
+const stdinName = "-"
+
+// listFlag collects the values of a flag that can be given multiple times
+// or as a comma separated list
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// inputFlags are the flags shared by all commands that read PHP source code
+type inputFlags struct {
+	exclude    listFlag
+	extensions listFlag
+	jobs       int
+	progress   bool
+}
+
+func addInputFlags(flags *flag.FlagSet) *inputFlags {
+	f := &inputFlags{}
+	flags.Var(&f.exclude, "exclude", "Exclude files and directories matching a .gitignore-style `pattern` (repeatable)")
+	flags.Var(&f.extensions, "ext", "File `extensions` to search directories for (default .php,.phtml,.inc)")
+	flags.IntVar(&f.jobs, "jobs", runtime.NumCPU(), "Number of files lexed in parallel")
+	flags.BoolVar(&f.progress, "progress", false, "Report progress on stderr")
+	return f
+}
+
+// resolveInputs expands the command line arguments into a list of input names.
+// Directories are searched recursively, globs are expanded and "-" (or no
+// argument at all) stands for stdin. A glob that matches no files is an error.
+func (f *inputFlags) resolveInputs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+
+	opts := scan.Options{Exclude: f.exclude, Extensions: f.extensions}
+	var inputs []string
+	for _, arg := range args {
+		if arg == stdinName {
+			inputs = append(inputs, stdinName)
+			continue
+		}
+
+		paths := []string{arg}
+		isGlob := strings.ContainsAny(arg, "*?[")
+		if isGlob {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			paths = matches
+		}
+
+		found := 0
+		for _, path := range paths {
+			files, err := scan.Walk(path, opts)
+			if err != nil {
+				return nil, err
+			}
+			found += len(files)
+			inputs = append(inputs, files...)
+		}
+		if isGlob && found == 0 {
+			// a typo in a pattern must not pass as a clean run
+			return nil, fmt.Errorf("no files match %s", arg)
+		}
+	}
+
+	return inputs, nil
+}
+
+// lexInputs lexes all inputs, files in parallel, and returns the results
+// in the order of the inputs
+func (f *inputFlags) lexInputs(inputs []string, stdin io.Reader, stderr io.Writer) []scan.Result {
+	results := make([]scan.Result, len(inputs))
+
+	var files []string
+	var indices []int
+	for i, name := range inputs {
+		if name == stdinName {
+			results[i] = scan.Read(name, stdin)
+			continue
+		}
+		files = append(files, name)
+		indices = append(indices, i)
+	}
+
+	var progress scan.Progress
+	if f.progress {
+		progress = scan.ProgressWriter(stderr)
+	}
+	for i, res := range scan.LexFiles(files, f.jobs, progress) {
+		results[indices[i]] = res
+	}
+
+	return results
+}
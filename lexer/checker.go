@@ -49,7 +49,7 @@ func (d delimiterChecker) Check(l *Lexer) (Token, bool) {
 			return newToken(DOT, c, l.line), true
 		}
 	case '&':
-		if l.input[l.readPosition] != '&' {
+		if l.peek(1) != "&" {
 			l.readChar()
 			return newToken(REFERENCE, c, l.line), true
 		}
@@ -77,7 +77,7 @@ type arithmeticChecker struct{}
 func (c arithmeticChecker) Check(l *Lexer) (Token, bool) {
 	var tok Token
 	if l.ch == '+' {
-		if l.input[l.readPosition] == '+' {
+		if l.peek(1) == "+" {
 			tok.Type = INC
 			tok.Literal = "++"
 			l.advance(2)
@@ -89,13 +89,13 @@ func (c arithmeticChecker) Check(l *Lexer) (Token, bool) {
 		return tok, true
 	}
 	if l.ch == '-' {
-		if l.input[l.readPosition] == '-' {
+		if l.peek(1) == "-" {
 			tok.Type = DEC
 			tok.Literal = "--"
 			l.advance(2)
 			return tok, true
 		}
-		if l.input[l.readPosition] != '>' {
+		if l.peek(1) != ">" {
 			l.readChar()
 			tok.Type = MINUS
 			tok.Literal = "-"
@@ -103,7 +103,7 @@ func (c arithmeticChecker) Check(l *Lexer) (Token, bool) {
 		}
 	}
 	if l.ch == '/' {
-		if l.input[l.readPosition] == '/' || l.input[l.readPosition] == '*' {
+		if l.peek(1) == "/" || l.peek(1) == "*" {
 			return tok, false
 		}
 		tok.Type = DIVIDE
@@ -261,7 +261,7 @@ func (c compareChecker) Check(l *Lexer) (Token, bool) {
 		return tok, true
 	}
 	if l.ch == '>' {
-		if (l.input[l.position+1]) == '=' {
+		if l.peek(1) == "=" {
 			tok.Type = GREATERTHANOREQUAL
 			tok.Literal = ">="
 			l.advance(2)
@@ -289,13 +289,13 @@ func (c compareChecker) Check(l *Lexer) (Token, bool) {
 		l.readChar()
 		return tok, true
 	}
-	if l.ch == '|' && l.input[l.readPosition] == '|' {
+	if l.ch == '|' && l.peek(1) == "|" {
 		tok.Type = OR
 		tok.Literal = "||"
 		l.advance(2)
 		return tok, true
 	}
-	if l.ch == '&' && l.input[l.readPosition] == '&' {
+	if l.ch == '&' && l.peek(1) == "&" {
 		tok.Type = AND
 		tok.Literal = "&&"
 		l.advance(2)
@@ -363,7 +363,7 @@ func (c commentChecker) Check(l *Lexer) (Token, bool) {
 			l.scan([]rune{'*', 0})
 			if l.ch == 0 {
 				tok.Type = COMMENT
-				tok.Literal = l.input[pos : l.position-1]
+				tok.Literal = l.input[pos:l.position]
 				return tok, true
 			}
 			l.readChar()
@@ -388,13 +388,13 @@ type arrowChecker struct{}
 
 func (a arrowChecker) Check(l *Lexer) (Token, bool) {
 	var tok Token
-	if l.ch == '-' && l.input[l.readPosition] == '>' {
+	if l.ch == '-' && l.peek(1) == ">" {
 		tok.Type = ARROW
 		tok.Literal = "->"
 		l.advance(2)
 		return tok, true
 	}
-	if l.ch == '=' && l.input[l.readPosition] == '>' {
+	if l.ch == '=' && l.peek(1) == ">" {
 		tok.Type = DOUBLEARROW
 		tok.Literal = "=>"
 		l.advance(2)
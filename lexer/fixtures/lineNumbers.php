@@ -3,4 +3,9 @@
 $line = 3;
 $line = 4;
 
-$line = 6;
\ No newline at end of file
+$line = 6;
+
+// comment on line 8
+/* comment
+starting on line 9 */
+$line = 11;
@@ -0,0 +1,74 @@
+package lexer
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Edit describes a change of the input: Length bytes starting at byte Offset
+// were replaced with Text
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// lookahead is the number of bytes after the end of a token the lexer may
+// have looked at to decide about it, e.g. "<?php" is recognized by looking
+// at the four characters following "<"
+const lookahead = 5 * utf8.UTFMax
+
+// Relex returns the tokens of src, which is the input old was lexed from with
+// the edit applied. Only the tokens the edit can affect are lexed again: the
+// lexer restarts after the last token that did not look at the edited part of
+// the input and stops as soon as it reaches the start of an old token behind
+// the edit. From there on the old tokens are reused with shifted offsets and lines.
+//
+// The lexer has no modes: strings and comments are read as a whole, so every
+// boundary between two tokens is a safe place to restart it. Like the result
+// of NextToken, old and the returned tokens do not contain the final EOF token.
+// As with New, the input ends at its first NUL byte.
+func Relex(old []Token, src string, e Edit) []Token {
+	if nul := strings.IndexByte(src, 0); nul >= 0 {
+		src = src[:nul+1]
+	}
+
+	// the first token whose lexing may depend on the edited part
+	first := sort.Search(len(old), func(i int) bool { return old[i].End+lookahead > e.Offset })
+
+	start, line := 0, 1
+	if first > 0 {
+		prev := old[first-1]
+		start = prev.End
+		line = prev.Line + strings.Count(src[prev.Offset:prev.End], "\n")
+	}
+
+	delta := len(e.Text) - e.Length
+
+	l := NewAt(src, start, line)
+
+	tokens := append([]Token(nil), old[:first]...)
+	next := first // the first old token that may still be reused
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		if tok.Offset >= e.Offset+len(e.Text) {
+			// the input from here on is the same as behind the edit in the old input
+			for next < len(old) && old[next].Offset < tok.Offset-delta {
+				next++
+			}
+			if next < len(old) && old[next].Offset == tok.Offset-delta && old[next].Offset >= e.Offset+e.Length {
+				lineDelta := tok.Line - old[next].Line
+				for _, o := range old[next:] {
+					o.Offset += delta
+					o.End += delta
+					o.Line += lineDelta
+					tokens = append(tokens, o)
+				}
+				return tokens
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+
+	return tokens
+}
@@ -28,7 +28,27 @@ func New(input io.Reader) (*Lexer, error) {
 		return nil, err
 	}
 
-	l := &Lexer{input: stringInput, line: 1}
+	l := newLexer(stringInput)
+	l.readChar()
+
+	return l, nil
+}
+
+// NewAt returns a lexer that starts reading src at the byte offset, which
+// must be the start of a character. The offsets of the tokens are relative to
+// src and their lines are counted from line.
+func NewAt(src string, offset, line int) *Lexer {
+	l := newLexer(src)
+	l.readPosition = offset
+	l.line = line
+	l.readChar()
+
+	return l
+}
+
+// newLexer returns a lexer for input that has not read its first character yet
+func newLexer(input string) *Lexer {
+	l := &Lexer{input: input, line: 1}
 	l.checkers = []checker{
 		delimiterChecker{},
 		eofChecker{},
@@ -49,14 +69,15 @@ func New(input io.Reader) (*Lexer, error) {
 		commentChecker{},
 		arrowChecker{},
 	}
-	l.readChar()
 
-	return l, nil
+	return l
 }
 
 func (l *Lexer) readChar() {
 	if l.readPosition >= len(l.input) {
-		l.ch = 0
+		// stay at the end of the input
+		l.ch, l.chsize = 0, 0
+		l.readPosition = len(l.input)
 	} else {
 		l.ch, l.chsize = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
@@ -113,18 +134,25 @@ func runeInSlice(b rune, s []rune) bool {
 func (l *Lexer) NextToken() Token {
 
 	l.skipWhitespace()
+	line := l.line // tokens are reported on the line they start on
+	offset := l.position
 
 	for _, c := range l.checkers {
 		if tok, ok := c.Check(l); ok {
-			tok.Line = l.line
+			tok.Line = line
+			tok.Offset = offset
+			tok.End = l.position
+			if tok.Type == EOF {
+				tok.End = offset
+			}
 			return tok
 		}
 	}
 
-	var tok Token
-	tok.Line = l.line
-	tok = newToken(ILLEGAL, l.ch, l.line)
+	tok := newToken(ILLEGAL, l.ch, line)
+	tok.Offset = offset
 	l.readChar()
+	tok.End = l.position
 
 	return tok
 }
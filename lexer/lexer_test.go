@@ -1,9 +1,15 @@
 package lexer
 
 import (
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 type testcase struct {
@@ -352,3 +358,180 @@ func TestLineSupport(t *testing.T) {
 	}
 
 }
+
+func TestTokenLines(t *testing.T) {
+
+	input, err := os.OpenFile("fixtures/lineNumbers.php", os.O_RDONLY, 0666)
+	defer input.Close()
+	if err != nil {
+		t.Fatal("Error reading fixture", err)
+	}
+
+	l, err := New(input)
+	if err != nil {
+		t.Fatal("error creating lexer", err)
+	}
+
+	expectedLines := []struct {
+		expectedType TokenType
+		expectedLine int
+	}{
+		{PHPTAG, 1},
+		{VAR, 3}, {ASSIGN, 3}, {INT, 3}, {SEMICOLON, 3},
+		{VAR, 4}, {ASSIGN, 4}, {INT, 4}, {SEMICOLON, 4},
+		{VAR, 6}, {ASSIGN, 6}, {INT, 6}, {SEMICOLON, 6},
+		{COMMENT, 8},
+		{COMMENT, 9},
+		{VAR, 11}, {ASSIGN, 11}, {INT, 11}, {SEMICOLON, 11},
+	}
+
+	for i, tt := range expectedLines {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line of %v wrong. expected=%d, got=%d", i, tok, tt.expectedLine, tok.Line)
+		}
+	}
+
+}
+
+func TestTokenOffsets(t *testing.T) {
+
+	fixtures, err := filepath.Glob("fixtures/*.php")
+	if err != nil {
+		t.Fatal("error listing fixtures", err)
+	}
+
+	for _, fixture := range fixtures {
+		input, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal("error reading test fixture", err)
+		}
+		src := string(input)
+		l, err := New(strings.NewReader(src))
+		if err != nil {
+			t.Fatal("error creating lexer", err)
+		}
+
+		end := 0
+		for tok := l.NextToken(); ; tok = l.NextToken() {
+			if tok.Offset < end || tok.End < tok.Offset || tok.End > len(src) {
+				t.Fatalf("%v - invalid offsets %d:%d for %v after %d", fixture, tok.Offset, tok.End, tok, end)
+			}
+			if strings.TrimSpace(src[end:tok.Offset]) != "" {
+				t.Fatalf("%v - source skipped before %v: %q", fixture, tok, src[end:tok.Offset])
+			}
+			end = tok.End
+
+			text := src[tok.Offset:tok.End]
+			switch tok.Type {
+			case EOF:
+				if tok.Offset != len(src) || text != "" {
+					t.Fatalf("%v - EOF at %d:%d, expected %d", fixture, tok.Offset, tok.End, len(src))
+				}
+			case DOUBLEQUOTEDSTRING, SINGLEQUOTEDSTRING, COMMENT:
+				if !strings.HasPrefix(text, "/") && !strings.HasPrefix(text, "\"") && !strings.HasPrefix(text, "'") {
+					t.Fatalf("%v - unexpected source %q for %v", fixture, text, tok)
+				}
+			default:
+				if text != tok.Literal {
+					t.Fatalf("%v - source %q does not match literal of %v", fixture, text, tok)
+				}
+			}
+			if tok.Type == EOF {
+				break
+			}
+		}
+	}
+
+}
+
+func TestOperatorAtEndOfInput(t *testing.T) {
+
+	for _, input := range []string{"&", "+", "-", "/", "=", ">", "|", "?>", `"a\`, "'\\", "/*"} {
+		l, err := New(strings.NewReader(input))
+		if err != nil {
+			t.Fatal("error creating lexer", err)
+		}
+
+		var tok Token
+		for i := 0; tok.Type != EOF; i++ {
+			if i > len(input) {
+				t.Fatalf("%q - no EOF after %d tokens", input, i)
+			}
+			tok = l.NextToken()
+		}
+	}
+
+}
+
+func TestUnterminatedComment(t *testing.T) {
+
+	l, err := New(strings.NewReader("/* open"))
+	if err != nil {
+		t.Fatal("error creating lexer", err)
+	}
+
+	tok := l.NextToken()
+	if tok.Type != COMMENT || tok.Literal != " open" {
+		t.Errorf("expected comment %q, got %s %q", " open", tok.Type, tok.Literal)
+	}
+
+}
+
+func TestRelex(t *testing.T) {
+
+	fixtures, err := filepath.Glob("fixtures/*.php")
+	if err != nil {
+		t.Fatal("error listing fixtures", err)
+	}
+
+	lexAll := func(src string) []Token {
+		l, err := New(strings.NewReader(src))
+		if err != nil {
+			t.Fatal("error creating lexer", err)
+		}
+		var tokens []Token
+		for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+			tokens = append(tokens, tok)
+		}
+		return tokens
+	}
+
+	// the inserted texts change how the surrounding code is split into tokens
+	texts := []string{"", "a", "=", " ", "\n", "'", "\"", "/*", "*/", "//", "$x", "1.5", "<?php", "\n\n}", "ä", "\x00"}
+	rnd := rand.New(rand.NewSource(1))
+
+	for _, fixture := range fixtures {
+		input, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal("error reading test fixture", err)
+		}
+		src := string(input)
+		tokens := lexAll(src)
+
+		for i := 0; i < 200; i++ {
+			e := Edit{Offset: rnd.Intn(len(src) + 1), Text: texts[rnd.Intn(len(texts))]}
+			e.Length = rnd.Intn(len(src)-e.Offset+1) % 8
+			// keep the edits on character boundaries
+			for e.Offset > 0 && e.Offset < len(src) && !utf8.RuneStart(src[e.Offset]) {
+				e.Offset--
+			}
+			for e.Offset+e.Length < len(src) && !utf8.RuneStart(src[e.Offset+e.Length]) {
+				e.Length++
+			}
+
+			edited := src[:e.Offset] + e.Text + src[e.Offset+e.Length:]
+			relexed := Relex(tokens, edited, e)
+			if expected := lexAll(edited); !reflect.DeepEqual(relexed, expected) {
+				t.Fatalf("%v - edit %+v: expected\n%v\ngot\n%v", fixture, e, expected, relexed)
+			}
+
+			// continue with the edited source, so the edits add up
+			src, tokens = edited, relexed
+		}
+	}
+
+}
@@ -2,9 +2,28 @@ package lexer
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 )
 
+// JSONVersion is the version of the JSON format produced by PrintJSON.
+// It is increased whenever the structure of the output changes.
+const JSONVersion = 2
+
+type jsonToken struct {
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Line    int       `json:"line"`
+	Offset  int       `json:"offset"`
+	End     int       `json:"end"`
+}
+
+type jsonDocument struct {
+	Version int         `json:"version"`
+	Path    string      `json:"path,omitempty"`
+	Tokens  []jsonToken `json:"tokens"`
+}
+
 // PrettyPrint prints the list of token in a sane way
 // (can be used for example for output on the command line)
 func PrettyPrint(t []Token) string {
@@ -16,3 +35,32 @@ func PrettyPrint(t []Token) string {
 
 	return b.String()
 }
+
+// PrintJSON prints the list of token as a versioned JSON document
+// (see tokens.schema.json for a description of the format)
+func PrintJSON(t []Token) (string, error) {
+	return PrintFileJSON("", t)
+}
+
+// PrintFileJSON prints the list of token like PrintJSON and adds the path of
+// the file the tokens were read from to the document
+func PrintFileJSON(path string, t []Token) (string, error) {
+	doc := jsonDocument{
+		Version: JSONVersion,
+		Path:    path,
+		Tokens:  make([]jsonToken, 0, len(t)),
+	}
+	for _, tok := range t {
+		doc.Tokens = append(doc.Tokens, jsonToken{tok.Type, tok.Literal, tok.Line, tok.Offset, tok.End})
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
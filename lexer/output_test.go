@@ -1,17 +1,23 @@
 package lexer
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 )
 
 func TestPrettyPrint(t *testing.T) {
 
 	input := []Token{
-		{PHPTAG, "<?php", 1},
-		{IDENT, "$foo", 2},
-		{ASSIGN, "=", 2},
-		{IDENT, "$bar", 2},
-		{SEMICOLON, ";", 2},
+		{Type: PHPTAG, Literal: "<?php", Line: 1},
+		{Type: IDENT, Literal: "$foo", Line: 2},
+		{Type: ASSIGN, Literal: "=", Line: 2},
+		{Type: IDENT, Literal: "$bar", Line: 2},
+		{Type: SEMICOLON, Literal: ";", Line: 2},
 	}
 
 	expectedOutputWithLines := "1\tPHPTAG\t<?php\n" +
@@ -27,3 +33,198 @@ func TestPrettyPrint(t *testing.T) {
 	}
 
 }
+
+func TestPrintJSON(t *testing.T) {
+
+	input := []Token{
+		{Type: PHPTAG, Literal: "<?php", Line: 1, Offset: 0, End: 5},
+		{Type: VAR, Literal: "$foo", Line: 2, Offset: 6, End: 10},
+		{Type: ASSIGN, Literal: "=", Line: 2, Offset: 11, End: 12},
+		{Type: DOUBLEQUOTEDSTRING, Literal: "bar", Line: 2, Offset: 13, End: 18},
+		{Type: SEMICOLON, Literal: ";", Line: 2, Offset: 18, End: 19},
+	}
+
+	expectedOutput := `{
+  "version": 2,
+  "tokens": [
+    {
+      "type": "PHPTAG",
+      "literal": "<?php",
+      "line": 1,
+      "offset": 0,
+      "end": 5
+    },
+    {
+      "type": "VAR",
+      "literal": "$foo",
+      "line": 2,
+      "offset": 6,
+      "end": 10
+    },
+    {
+      "type": "ASSIGN",
+      "literal": "=",
+      "line": 2,
+      "offset": 11,
+      "end": 12
+    },
+    {
+      "type": "DOUBLEQUOTEDSTRING",
+      "literal": "bar",
+      "line": 2,
+      "offset": 13,
+      "end": 18
+    },
+    {
+      "type": "SEMICOLON",
+      "literal": ";",
+      "line": 2,
+      "offset": 18,
+      "end": 19
+    }
+  ]
+}
+`
+
+	output, err := PrintJSON(input)
+	if err != nil {
+		t.Fatal("error printing JSON", err)
+	}
+
+	if output != expectedOutput {
+		t.Fatalf("JSON output does not match expected output:\nEXPECTED:\n%s\n\nACTUAL:\n%s", expectedOutput, output)
+	}
+
+	empty, err := PrintJSON(nil)
+	if err != nil {
+		t.Fatal("error printing JSON", err)
+	}
+	if empty != "{\n  \"version\": 2,\n  \"tokens\": []\n}\n" {
+		t.Fatalf("expected an empty token list, got %s", empty)
+	}
+
+	withPath, err := PrintFileJSON("a.php", nil)
+	if err != nil {
+		t.Fatal("error printing JSON", err)
+	}
+	if withPath != "{\n  \"version\": 2,\n  \"path\": \"a.php\",\n  \"tokens\": []\n}\n" {
+		t.Fatalf("expected an empty token list with a path, got %s", withPath)
+	}
+
+}
+
+func TestPrintJSONMatchesSchema(t *testing.T) {
+
+	raw, err := ioutil.ReadFile("tokens.schema.json")
+	if err != nil {
+		t.Fatal("error reading schema", err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		t.Fatal("error decoding schema", err)
+	}
+
+	fixtures, err := filepath.Glob("fixtures/*.php")
+	if err != nil {
+		t.Fatal("error listing fixtures", err)
+	}
+
+	for _, fixture := range fixtures {
+		input, err := os.Open(fixture)
+		if err != nil {
+			t.Fatal("error opening test fixture", err)
+		}
+		l, err := New(input)
+		input.Close()
+		if err != nil {
+			t.Fatal("error creating lexer", err)
+		}
+
+		var tokens []Token
+		for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+			tokens = append(tokens, tok)
+		}
+
+		output, err := PrintFileJSON(fixture, tokens)
+		if err != nil {
+			t.Fatal("error printing JSON", err)
+		}
+		var doc interface{}
+		if err := json.Unmarshal([]byte(output), &doc); err != nil {
+			t.Fatal("error decoding JSON output", err)
+		}
+		if err := validate(schema, doc, "$"); err != nil {
+			t.Fatalf("%v: output does not match schema: %v", fixture, err)
+		}
+	}
+
+}
+
+// validate checks value against the subset of JSON Schema used in tokens.schema.json
+func validate(schema map[string]interface{}, value interface{}, path string) error {
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := obj[name.(string)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		for name, v := range obj {
+			sub, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+				continue
+			}
+			if err := validate(sub, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		for i, v := range arr {
+			if err := validate(items, v, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s: %q does not match %s", path, s, pattern)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return fmt.Errorf("%s: %v is less than %v", path, n, min)
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, e := range enum {
+			if e == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+	}
+
+	return nil
+}
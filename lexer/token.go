@@ -10,6 +10,8 @@ type Token struct {
 	Type    TokenType
 	Literal string
 	Line    int
+	Offset  int // byte offset of the first character of the token in the input
+	End     int // byte offset after the last character of the token in the input
 }
 
 const (
@@ -0,0 +1,201 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config enables, disables and configures rules. It is read from a JSON file like
+//
+//	{
+//	  "rules": {
+//	    "line-length": {"severity": "error", "options": {"max": 100}}
+//	  },
+//	  "overrides": [
+//	    {"directory": "legacy", "rules": {"loose-comparison": {"enabled": false}}}
+//	  ]
+//	}
+//
+// Overrides apply to all files below their directory, later overrides win.
+// LoadConfig resolves relative directories against the directory of the
+// configuration file, ParseConfig against the working directory.
+type Config struct {
+	Rules     map[string]RuleConfig `json:"rules"`
+	Overrides []Override            `json:"overrides"`
+}
+
+// RuleConfig holds the settings of one rule. Unset fields keep their previous value.
+type RuleConfig struct {
+	Enabled  *bool           `json:"enabled,omitempty"`
+	Severity string          `json:"severity,omitempty"`
+	Options  json.RawMessage `json:"options,omitempty"`
+}
+
+// Override changes rule settings for the files below a directory
+type Override struct {
+	Directory string                `json:"directory"`
+	Rules     map[string]RuleConfig `json:"rules"`
+}
+
+// Configurable is implemented by rules that take options
+type Configurable interface {
+	Configure(options json.RawMessage) error
+}
+
+// LoadConfig reads the configuration from a JSON file
+func LoadConfig(filename string) (*Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := ParseConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	for i, o := range cfg.Overrides {
+		if !filepath.IsAbs(o.Directory) {
+			cfg.Overrides[i].Directory = filepath.Join(filepath.Dir(filename), o.Directory)
+		}
+	}
+
+	return cfg, nil
+}
+
+// ParseConfig reads the configuration from r and checks that all rules exist
+func ParseConfig(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("error parsing configuration: %v", err)
+	}
+
+	sections := []map[string]RuleConfig{cfg.Rules}
+	for _, o := range cfg.Overrides {
+		sections = append(sections, o.Rules)
+	}
+	for _, rules := range sections {
+		for id, rc := range rules {
+			if _, ok := registry[id]; !ok {
+				return nil, fmt.Errorf("unknown rule %q", id)
+			}
+			if rc.Severity != "" {
+				if _, err := ParseSeverity(rc.Severity); err != nil {
+					return nil, fmt.Errorf("rule %q: %v", id, err)
+				}
+			}
+		}
+	}
+
+	return &cfg, nil
+}
+
+// configuredRule is a rule ready to check a file
+type configuredRule struct {
+	Rule
+	severity Severity
+}
+
+// rulesFor returns the enabled rules for the file at path, ordered by ID
+func (c *Config) rulesFor(path string) ([]configuredRule, error) {
+	settings := map[string]RuleConfig{}
+	if c != nil {
+		merge(settings, c.Rules)
+		for _, o := range c.Overrides {
+			if inDirectory(path, o.Directory) {
+				merge(settings, o.Rules)
+			}
+		}
+	}
+
+	var rules []configuredRule
+	for _, id := range RuleIDs() {
+		rc := settings[id]
+		if rc.Enabled != nil && !*rc.Enabled {
+			continue
+		}
+
+		r := registry[id]()
+		severity := r.DefaultSeverity()
+		if rc.Severity != "" {
+			severity, _ = ParseSeverity(rc.Severity)
+		}
+		if rc.Options != nil {
+			configurable, ok := r.(Configurable)
+			if !ok {
+				return nil, fmt.Errorf("rule %q does not take options", id)
+			}
+			if err := configurable.Configure(rc.Options); err != nil {
+				return nil, fmt.Errorf("rule %q: %v", id, err)
+			}
+		}
+		rules = append(rules, configuredRule{r, severity})
+	}
+
+	return rules, nil
+}
+
+func merge(settings, rules map[string]RuleConfig) {
+	for id, rc := range rules {
+		s := settings[id]
+		if rc.Enabled != nil {
+			s.Enabled = rc.Enabled
+		}
+		if rc.Severity != "" {
+			s.Severity = rc.Severity
+		}
+		if rc.Options != nil {
+			s.Options = rc.Options
+		}
+		settings[id] = s
+	}
+}
+
+// inDirectory reports whether path is below dir. Relative paths are taken
+// relative to the working directory.
+func inDirectory(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// registry holds a constructor for every known rule by ID
+var registry = map[string]func() Rule{}
+
+// Register makes a rule known to the linter. It panics if the ID is already taken.
+func Register(newRule func() Rule) {
+	id := newRule().ID()
+	if _, ok := registry[id]; ok {
+		panic("lint: rule registered twice: " + id)
+	}
+	registry[id] = newRule
+}
+
+// RuleIDs returns the IDs of all registered rules in alphabetical order
+func RuleIDs() []string {
+	var ids []string
+	for id := range registry {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
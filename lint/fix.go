@@ -0,0 +1,111 @@
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// maxFixRounds limits how often Fix re-runs the rules to apply fixes that
+// overlapped with others in an earlier round
+const maxFixRounds = 10
+
+// Edit replaces Length bytes of the source starting at byte Offset with Text
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// SuggestedFix is a change to the source that resolves a diagnostic
+type SuggestedFix struct {
+	Message string
+	Edits   []Edit
+}
+
+// NewFile lexes src and returns it ready to be linted
+func NewFile(path, src string) (*File, error) {
+	l, err := lexer.New(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &File{Path: path, Source: src}
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		f.Tokens = append(f.Tokens, tok)
+	}
+
+	return f, nil
+}
+
+// ApplyFixes applies the suggested fixes of the diagnostics to src. A fix
+// overlapping with an earlier one is skipped as a whole; it can be applied
+// by linting the result again. It returns the new source and the number of
+// fixes applied.
+func ApplyFixes(src string, diagnostics []Diagnostic) (string, int) {
+	type fix struct {
+		start, end int
+		edits      []Edit
+	}
+	var fixes []fix
+	for _, d := range diagnostics {
+		if d.Fix == nil || len(d.Fix.Edits) == 0 {
+			continue
+		}
+		edits := append([]Edit(nil), d.Fix.Edits...)
+		sort.Slice(edits, func(i, j int) bool { return edits[i].Offset < edits[j].Offset })
+		last := edits[len(edits)-1]
+		fixes = append(fixes, fix{edits[0].Offset, last.Offset + last.Length, edits})
+	}
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].start < fixes[j].start })
+
+	var b strings.Builder
+	pos, applied := 0, 0
+	for _, f := range fixes {
+		if f.start < pos || !editsValid(f.edits, len(src)) {
+			continue
+		}
+		for _, e := range f.edits {
+			b.WriteString(src[pos:e.Offset])
+			b.WriteString(e.Text)
+			pos = e.Offset + e.Length
+		}
+		applied++
+	}
+	b.WriteString(src[pos:])
+
+	return b.String(), applied
+}
+
+// editsValid reports whether the sorted edits are inside the source and do not overlap
+func editsValid(edits []Edit, size int) bool {
+	pos := 0
+	for _, e := range edits {
+		if e.Offset < pos || e.Length < 0 || e.Offset+e.Length > size {
+			return false
+		}
+		pos = e.Offset + e.Length
+	}
+	return true
+}
+
+// Fix lints the file and applies all suggested fixes, repeating until no more
+// fixes apply. It returns the fixed file and its remaining diagnostics.
+func Fix(f *File, cfg *Config) (*File, []Diagnostic, error) {
+	for round := 0; ; round++ {
+		diagnostics, err := Lint(f, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		src, applied := ApplyFixes(f.Source, diagnostics)
+		if applied == 0 || round == maxFixRounds {
+			return f, diagnostics, nil
+		}
+
+		if f, err = NewFile(f.Path, src); err != nil {
+			return nil, nil, err
+		}
+	}
+}
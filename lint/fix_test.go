@@ -0,0 +1,95 @@
+package lint
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFixes(t *testing.T) {
+
+	src := "0123456789"
+	diagnostics := []Diagnostic{
+		{Line: 1, Message: "no fix"},
+		{Line: 1, Fix: &SuggestedFix{Edits: []Edit{{8, 2, "ab"}}}},
+		{Line: 1, Fix: &SuggestedFix{Edits: []Edit{{4, 0, "-"}, {1, 1, "x"}}}},
+		{Line: 1, Fix: &SuggestedFix{Edits: []Edit{{2, 4, "overlaps"}}}},
+		{Line: 1, Fix: &SuggestedFix{Edits: []Edit{{10, 1, "out of range"}}}},
+		{Line: 1, Fix: &SuggestedFix{Edits: []Edit{{6, 1, ""}}}},
+	}
+
+	fixed, applied := ApplyFixes(src, diagnostics)
+	if fixed != "0x23-457ab" || applied != 3 {
+		t.Fatalf("expected 3 fixes applied giving %q, got %d giving %q", "0x23-457ab", applied, fixed)
+	}
+
+}
+
+func TestFix(t *testing.T) {
+
+	tests := []struct {
+		filename  string
+		expected  string
+		remaining []string
+	}{
+		{"fixtures/fixes.php", "fixtures/fixes.fixed.php", []string{"fixtures/fixes.php:3: warning: use === instead of == (loose-comparison)"}},
+		// text outside of the PHP tags is left alone
+		{"fixtures/template.phtml", "fixtures/template.fixed.phtml", nil},
+	}
+
+	for _, tt := range tests {
+		f := loadFile(t, tt.filename)
+		expected, err := ioutil.ReadFile(tt.expected)
+		if err != nil {
+			t.Fatal("error reading test fixture", err)
+		}
+
+		fixed, remaining, err := Fix(f, nil)
+		if err != nil {
+			t.Fatal("error fixing file", err)
+		}
+		if fixed.Source != string(expected) {
+			t.Fatalf("%v - fixed source wrong:\nEXPECTED:\n%q\nACTUAL:\n%q", tt.filename, expected, fixed.Source)
+		}
+
+		var messages []string
+		for _, d := range remaining {
+			if d.Fix != nil {
+				t.Fatalf("%v - fixable diagnostic left after fixing: %v", tt.filename, d)
+			}
+			messages = append(messages, d.String())
+		}
+		if !reflect.DeepEqual(messages, tt.remaining) {
+			t.Fatalf("%v - remaining diagnostics wrong.\nexpected=%s\ngot=     %s", tt.filename, strings.Join(tt.remaining, "\n"), strings.Join(messages, "\n"))
+		}
+
+		// fixing a fixed file must not change it any more
+		again, _, err := Fix(fixed, nil)
+		if err != nil {
+			t.Fatal("error fixing file", err)
+		}
+		if again.Source != fixed.Source {
+			t.Fatalf("%v - fixing is not idempotent:\nFIRST:\n%q\nSECOND:\n%q", tt.filename, fixed.Source, again.Source)
+		}
+	}
+
+}
+
+func TestFixFixtures(t *testing.T) {
+
+	for _, filename := range []string{"fixtures/rules.php", "fixtures/fixes.php", "fixtures/fixes.fixed.php"} {
+		once, _, err := Fix(loadFile(t, filename), nil)
+		if err != nil {
+			t.Fatal("error fixing file", err)
+		}
+		twice, _, err := Fix(once, nil)
+		if err != nil {
+			t.Fatal("error fixing file", err)
+		}
+		if once.Source != twice.Source {
+			t.Fatalf("%v - fixing is not idempotent:\nFIRST:\n%q\nSECOND:\n%q", filename, once.Source, twice.Source)
+		}
+	}
+
+}
@@ -0,0 +1,23 @@
+<?php
+
+if ($a == true) {
+    $s = "multi   
+line";
+    return null;
+} else {
+    $b->New = Foo::NULL;
+}
+$c = 1;
+class A {
+    const NEW = 1;
+    public function Echo() {
+        return self::NEW;
+    }
+    function &Throw() {}
+}
+$h = <<<EOT
+  keeps its spaces   
+  EOT;
+$n = <<<'END'
+and its tabs	
+END;
@@ -0,0 +1,13 @@
+<?php
+
+if ($a == $b || $a != $c || $a !== $d) {
+    $e = 10 % 3; 
+}
+
+$long = "this line is longer than forty characters";
+
+// shmehashme-ignore loose-comparison
+$x = $a == $b;
+$y = $a == $b; // shmehashme-ignore
+/* shmehashme-ignore illegal-character, loose-comparison */
+$z = $a == @$b;
@@ -0,0 +1,191 @@
+// Package lint runs configurable rules over PHP source code and reports diagnostics
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+	"github.com/bestform/shmehashme/phptokens"
+)
+
+// Severity tells how serious a diagnostic is
+type Severity int
+
+const (
+	// Info is a hint that does not need to be acted upon
+	Info Severity = iota
+	// Warning points to code that is likely wrong or hard to maintain
+	Warning
+	// Error points to code that is broken
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity returns the severity with the given name
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+
+	return Info, fmt.Errorf("unknown severity %q", name)
+}
+
+// Diagnostic is a problem a rule found in a file
+type Diagnostic struct {
+	Path     string
+	Line     int
+	Rule     string
+	Severity Severity
+	Message  string
+	Fix      *SuggestedFix // nil if the rule cannot fix the problem
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s (%s)", d.Path, d.Line, d.Severity, d.Message, d.Rule)
+}
+
+// File is the input of the rules
+type File struct {
+	Path   string
+	Source string
+	Tokens []lexer.Token // without the final EOF token
+	lines  []string
+	php    []int // start and end offsets of the PHP code, see InPHP
+}
+
+// Lines returns the lines of the source code without line endings
+func (f *File) Lines() []string {
+	if f.lines == nil {
+		f.lines = strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n")
+		for i, l := range f.lines {
+			f.lines[i] = strings.TrimSuffix(l, "\r")
+		}
+	}
+	return f.lines
+}
+
+// InPHP reports whether the byte at offset belongs to PHP code, including
+// the open and close tags, rather than to the text around it. The lexer does
+// not know about text outside of the tags and reads it as code.
+func (f *File) InPHP(offset int) bool {
+	if f.php == nil {
+		f.php = []int{}
+		start := 0
+		for _, tok := range phptokens.Convert(f.Source) {
+			end := start + len(tok.Text)
+			if tok.Name != "T_INLINE_HTML" {
+				if n := len(f.php); n > 0 && f.php[n-1] == start {
+					f.php[n-1] = end
+				} else {
+					f.php = append(f.php, start, end)
+				}
+			}
+			start = end
+		}
+	}
+
+	// an odd number of offsets up to offset means it is in a range
+	return sort.SearchInts(f.php, offset+1)%2 == 1
+}
+
+// Rule is a single lint check. A rule must also implement TokenRule or FileRule.
+type Rule interface {
+	// ID is the name of the rule used in configuration files and suppressions
+	ID() string
+	// DefaultSeverity is used unless the configuration overrides it
+	DefaultSeverity() Severity
+}
+
+// TokenRule is a Rule that visits the tokens of a file one by one
+type TokenRule interface {
+	Rule
+	VisitToken(p *Pass, i int)
+}
+
+// FileRule is a Rule that looks at a file as a whole
+type FileRule interface {
+	Rule
+	CheckFile(p *Pass)
+}
+
+// Pass is handed to a rule while it checks a file
+type Pass struct {
+	File        *File
+	rule        Rule
+	severity    Severity
+	diagnostics *[]Diagnostic
+}
+
+// Report adds a diagnostic for the given line
+func (p *Pass) Report(line int, format string, args ...interface{}) {
+	*p.diagnostics = append(*p.diagnostics, Diagnostic{
+		Path:     p.File.Path,
+		Line:     line,
+		Rule:     p.rule.ID(),
+		Severity: p.severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ReportFix adds a diagnostic for the given line together with a fix for it
+func (p *Pass) ReportFix(line int, fix SuggestedFix, format string, args ...interface{}) {
+	p.Report(line, format, args...)
+	(*p.diagnostics)[len(*p.diagnostics)-1].Fix = &fix
+}
+
+// Lint checks the file with all rules cfg enables for its path and returns
+// the diagnostics that are not suppressed, ordered by line and rule. A nil cfg
+// enables all rules with their default settings.
+func Lint(f *File, cfg *Config) ([]Diagnostic, error) {
+	rules, err := cfg.rulesFor(f.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []Diagnostic
+	var tokenPasses []*Pass
+	var tokenRules []TokenRule
+	for _, r := range rules {
+		p := &Pass{File: f, rule: r.Rule, severity: r.severity, diagnostics: &diagnostics}
+		switch rule := r.Rule.(type) {
+		case TokenRule:
+			tokenPasses = append(tokenPasses, p)
+			tokenRules = append(tokenRules, rule)
+		case FileRule:
+			rule.CheckFile(p)
+		}
+	}
+	for i := range f.Tokens {
+		for j, rule := range tokenRules {
+			rule.VisitToken(tokenPasses[j], i)
+		}
+	}
+
+	suppressed := suppressions(f.Tokens)
+	var result []Diagnostic
+	for _, d := range diagnostics {
+		if !suppressed.matches(d) {
+			result = append(result, d)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Line != result[j].Line {
+			return result[i].Line < result[j].Line
+		}
+		return result[i].Rule < result[j].Rule
+	})
+
+	return result, nil
+}
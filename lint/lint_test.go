@@ -0,0 +1,176 @@
+package lint
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+func loadFile(t *testing.T, filename string) *File {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal("error reading test fixture", err)
+	}
+	return newFile(t, filename, string(src))
+}
+
+func newFile(t *testing.T, path, src string) *File {
+	f, err := NewFile(path, src)
+	if err != nil {
+		t.Fatal("error creating file", err)
+	}
+	return f
+}
+
+func lintStrings(t *testing.T, f *File, cfg *Config) []string {
+	diagnostics, err := Lint(f, cfg)
+	if err != nil {
+		t.Fatal("error linting file", err)
+	}
+	var result []string
+	for _, d := range diagnostics {
+		result = append(result, d.String())
+	}
+	return result
+}
+
+func TestLintDefaults(t *testing.T) {
+
+	expected := []string{
+		"fixtures/rules.php:3: warning: use === instead of == (loose-comparison)",
+		"fixtures/rules.php:3: warning: use !== instead of != (loose-comparison)",
+		"fixtures/rules.php:4: error: illegal character \"%\" (illegal-character)",
+		"fixtures/rules.php:4: info: trailing whitespace (trailing-whitespace)",
+	}
+
+	actual := lintStrings(t, loadFile(t, "fixtures/rules.php"), nil)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("diagnostics wrong.\nexpected=%s\ngot=     %s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+}
+
+func TestLintConfig(t *testing.T) {
+
+	cfg, err := LoadConfig("fixtures/config.json")
+	if err != nil {
+		t.Fatal("error loading config", err)
+	}
+
+	tests := []struct {
+		filename string
+		expected []string
+	}{
+		{
+			"fixtures/rules.php",
+			[]string{
+				"fixtures/rules.php:3: warning: use === instead of == (loose-comparison)",
+				"fixtures/rules.php:3: warning: use !== instead of != (loose-comparison)",
+				"fixtures/rules.php:4: error: illegal character \"%\" (illegal-character)",
+				"fixtures/rules.php:4: warning: trailing whitespace (trailing-whitespace)",
+				"fixtures/rules.php:7: warning: line is 52 characters long (max 40) (line-length)",
+				"fixtures/rules.php:12: warning: line is 59 characters long (max 40) (line-length)",
+			},
+		},
+		{
+			"fixtures/legacy/rules.php",
+			[]string{
+				"fixtures/legacy/rules.php:4: error: illegal character \"%\" (illegal-character)",
+				"fixtures/legacy/rules.php:4: warning: trailing whitespace (trailing-whitespace)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		actual := lintStrings(t, loadFile(t, tt.filename), cfg)
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Fatalf("%v - diagnostics wrong.\nexpected=%s\ngot=     %s", tt.filename,
+				strings.Join(tt.expected, "\n"), strings.Join(actual, "\n"))
+		}
+	}
+
+	// the override directory is relative to the configuration file, not to
+	// the working directory
+	actual := lintStrings(t, newFile(t, "legacy/rules.php", "<?php\n$a == 1;\n"), cfg)
+	expected := []string{"legacy/rules.php:2: warning: use === instead of == (loose-comparison)"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("diagnostics wrong.\nexpected=%s\ngot=     %s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+}
+
+func TestParseConfigErrors(t *testing.T) {
+
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{`{"rules": {"no-such-rule": {}}}`, `unknown rule "no-such-rule"`},
+		{`{"overrides": [{"directory": "a", "rules": {"no-such-rule": {}}}]}`, `unknown rule "no-such-rule"`},
+		{`{"rules": {"line-length": {"severity": "fatal"}}}`, `rule "line-length": unknown severity "fatal"`},
+		{`{"rulez": {}}`, `error parsing configuration: json: unknown field "rulez"`},
+	}
+
+	for i, tt := range tests {
+		_, err := ParseConfig(strings.NewReader(tt.config))
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("tests[%d] - expected error %q, got %v", i, tt.expected, err)
+		}
+	}
+
+	optionTests := []struct {
+		config   string
+		expected string
+	}{
+		{`{"rules": {"line-length": {"options": {"max": 0}}}}`, `rule "line-length": max must be positive, got 0`},
+		{`{"rules": {"line-length": {"options": {"min": 1}}}}`, `rule "line-length": json: unknown field "min"`},
+		{`{"rules": {"loose-comparison": {"options": {}}}}`, `rule "loose-comparison" does not take options`},
+	}
+
+	for i, tt := range optionTests {
+		cfg, err := ParseConfig(strings.NewReader(tt.config))
+		if err != nil {
+			t.Fatal("error parsing config", err)
+		}
+		_, err = Lint(newFile(t, "a.php", "<?php\n"), cfg)
+		if err == nil || err.Error() != tt.expected {
+			t.Fatalf("optionTests[%d] - expected error %q, got %v", i, tt.expected, err)
+		}
+	}
+
+}
+
+func TestAnnotatedLine(t *testing.T) {
+
+	f := newFile(t, "a.php", "<?php\n$a; // trailing\n// own line\n\n/* multi\nline */\n$b;\n// at the end\n")
+
+	expected := []struct {
+		line int
+		ok   bool
+	}{
+		{2, true},
+		{7, true},
+		{7, true},
+		{0, false},
+	}
+
+	var comments []int
+	for i, tok := range f.Tokens {
+		if tok.Type == lexer.COMMENT {
+			comments = append(comments, i)
+		}
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %d", len(expected), len(comments))
+	}
+	for i, c := range comments {
+		line, ok := AnnotatedLine(f.Tokens, c)
+		if line != expected[i].line || ok != expected[i].ok {
+			t.Fatalf("comments[%d] - expected line %d (%v), got %d (%v)", i, expected[i].line, expected[i].ok, line, ok)
+		}
+	}
+
+}
@@ -0,0 +1,190 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+func init() {
+	Register(func() Rule { return illegalCharacter{} })
+	Register(func() Rule { return looseComparison{} })
+	Register(func() Rule { return &lineLength{Max: 120} })
+	Register(func() Rule { return trailingWhitespace{} })
+	Register(func() Rule { return keywordCase{} })
+}
+
+// illegalCharacter reports characters the lexer does not understand
+type illegalCharacter struct{}
+
+func (illegalCharacter) ID() string                { return "illegal-character" }
+func (illegalCharacter) DefaultSeverity() Severity { return Error }
+
+func (illegalCharacter) VisitToken(p *Pass, i int) {
+	tok := p.File.Tokens[i]
+	if tok.Type == lexer.ILLEGAL {
+		p.Report(tok.Line, "illegal character %q", tok.Literal)
+	}
+}
+
+// looseComparison reports == and != which compare with type juggling
+type looseComparison struct{}
+
+func (looseComparison) ID() string                { return "loose-comparison" }
+func (looseComparison) DefaultSeverity() Severity { return Warning }
+
+func (looseComparison) VisitToken(p *Pass, i int) {
+	tokens := p.File.Tokens
+	tok := tokens[i]
+
+	// the lexer splits "!==" into NOT and EQUALS and "!=" into NOT and ASSIGN
+	switch {
+	case tok.Type == lexer.EQUALS && (i == 0 || tokens[i-1].Type != lexer.NOT):
+		p.Report(tok.Line, "use === instead of ==")
+	case tok.Type == lexer.NOT && i+1 < len(tokens) && tokens[i+1].Type == lexer.ASSIGN:
+		p.Report(tok.Line, "use !== instead of !=")
+	}
+}
+
+// lineLength reports lines longer than Max characters
+type lineLength struct {
+	Max int `json:"max"`
+}
+
+func (*lineLength) ID() string                { return "line-length" }
+func (*lineLength) DefaultSeverity() Severity { return Warning }
+
+func (r *lineLength) Configure(options json.RawMessage) error {
+	dec := json.NewDecoder(strings.NewReader(string(options)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(r); err != nil {
+		return err
+	}
+	if r.Max < 1 {
+		return fmt.Errorf("max must be positive, got %d", r.Max)
+	}
+	return nil
+}
+
+func (r *lineLength) CheckFile(p *Pass) {
+	for i, line := range p.File.Lines() {
+		if n := utf8.RuneCountInString(line); n > r.Max {
+			p.Report(i+1, "line is %d characters long (max %d)", n, r.Max)
+		}
+	}
+}
+
+// trailingWhitespace reports spaces and tabs at the end of a line outside of strings
+type trailingWhitespace struct{}
+
+func (trailingWhitespace) ID() string                { return "trailing-whitespace" }
+func (trailingWhitespace) DefaultSeverity() Severity { return Info }
+
+func (trailingWhitespace) CheckFile(p *Pass) {
+	src := p.File.Source
+	offset := 0 // of the current line in the source
+	label := "" // of the heredoc or nowdoc whose body the line is in
+	for i, line := range p.File.Lines() {
+		trimmed := strings.TrimRight(line, " \t")
+		start := offset + len(trimmed)
+		offset += len(line) + 1
+		if offset <= len(src) && src[offset-1] == '\r' {
+			offset++ // Lines strips "\r\n"
+		}
+
+		// the lexer does not know heredocs, whitespace in their bodies is part of the string
+		inHeredoc := label != ""
+		if inHeredoc && closesHeredoc(line, label) {
+			label, inHeredoc = "", false
+		} else if m := heredocPattern.FindStringSubmatch(trimmed); !inHeredoc && m != nil {
+			label = m[1]
+		}
+		if trimmed == line || inHeredoc || inString(p.File.Tokens, start) {
+			continue
+		}
+
+		fix := SuggestedFix{"remove trailing whitespace", []Edit{{start, len(line) - len(trimmed), ""}}}
+		p.ReportFix(i+1, fix, "trailing whitespace")
+	}
+}
+
+// heredocPattern matches a line ending with the start of a heredoc or nowdoc
+var heredocPattern = regexp.MustCompile(`<<<[ \t]*["']?([a-zA-Z_\x80-\xff][a-zA-Z0-9_\x80-\xff]*)["']?$`)
+
+// closesHeredoc reports whether the line holds the closing label of a
+// heredoc, which may be indented and followed by more code
+func closesHeredoc(line, label string) bool {
+	rest := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(rest, label) {
+		return false
+	}
+	rest = rest[len(label):]
+	return rest == "" || !isLabelChar(rest[0])
+}
+
+func isLabelChar(c byte) bool {
+	return c == '_' || c >= 0x80 || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// inString reports whether the byte at offset belongs to a string literal
+func inString(tokens []lexer.Token, offset int) bool {
+	for _, tok := range tokens {
+		if tok.Offset > offset {
+			break
+		}
+		if (tok.Type == lexer.DOUBLEQUOTEDSTRING || tok.Type == lexer.SINGLEQUOTEDSTRING) && offset < tok.End {
+			return true
+		}
+	}
+	return false
+}
+
+// keywordCase reports keywords and the constants true, false and null
+// that are not written in lower case
+type keywordCase struct{}
+
+// extraKeywords are keywords the lexer does not have token types for yet
+var extraKeywords = map[string]bool{
+	"abstract": true, "break": true, "case": true, "catch": true, "continue": true,
+	"do": true, "echo": true, "else": true, "elseif": true, "final": true,
+	"finally": true, "interface": true, "namespace": true, "new": true, "null": true,
+	"switch": true, "throw": true, "trait": true, "try": true, "while": true,
+}
+
+func (keywordCase) ID() string                { return "keyword-case" }
+func (keywordCase) DefaultSeverity() Severity { return Warning }
+
+func (keywordCase) VisitToken(p *Pass, i int) {
+	tokens := p.File.Tokens
+	tok := tokens[i]
+	if tok.Type != lexer.IDENT || !p.File.InPHP(tok.Offset) {
+		// text outside of the PHP tags is printed as it is
+		return
+	}
+	// properties and class constants are case sensitive, so $a->New and A::NULL stay as they are
+	if i > 0 && (tokens[i-1].Type == lexer.ARROW || tokens[i-1].Type == lexer.COLON) {
+		return
+	}
+	// so are constants in their declaration, and functions may be named like
+	// keywords: const NEW = 1; function Echo() and function &Echo()
+	switch {
+	case i > 0 && tokens[i-1].Type == lexer.IDENT && strings.EqualFold(tokens[i-1].Literal, "const"):
+		return
+	case i > 0 && tokens[i-1].Type == lexer.FUNCTION:
+		return
+	case i > 1 && tokens[i-1].Type == lexer.REFERENCE && tokens[i-2].Type == lexer.FUNCTION:
+		return
+	}
+
+	lower := strings.ToLower(tok.Literal)
+	if lower == tok.Literal || (lexer.LookupIdent(lower) == lexer.IDENT && !extraKeywords[lower]) {
+		return
+	}
+
+	fix := SuggestedFix{"write " + lower + " in lower case", []Edit{{tok.Offset, tok.End - tok.Offset, lower}}}
+	p.ReportFix(tok.Line, fix, "%s should be written in lower case", tok.Literal)
+}
@@ -0,0 +1,71 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// suppressPrefix starts a comment that suppresses diagnostics, e.g.
+// "// shmehashme-ignore loose-comparison". Without rule IDs all rules are suppressed.
+const suppressPrefix = "shmehashme-ignore"
+
+// suppressionSet maps a line to the suppressed rule IDs ("" for all rules)
+type suppressionSet map[int]map[string]bool
+
+func (s suppressionSet) matches(d Diagnostic) bool {
+	rules := s[d.Line]
+	return rules[""] || rules[d.Rule]
+}
+
+func suppressions(tokens []lexer.Token) suppressionSet {
+	s := suppressionSet{}
+	for i, tok := range tokens {
+		if tok.Type != lexer.COMMENT {
+			continue
+		}
+		text := strings.TrimSpace(tok.Literal)
+		if !strings.HasPrefix(text, suppressPrefix) {
+			continue
+		}
+		line, ok := AnnotatedLine(tokens, i)
+		if !ok {
+			continue
+		}
+
+		if s[line] == nil {
+			s[line] = map[string]bool{}
+		}
+		ids := strings.FieldsFunc(text[len(suppressPrefix):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(ids) == 0 {
+			s[line][""] = true
+		}
+		for _, id := range ids {
+			s[line][id] = true
+		}
+	}
+
+	return s
+}
+
+// AnnotatedLine returns the line the comment at index i refers to. A comment
+// following code on the same line annotates that line, a comment on a line
+// of its own annotates the next line containing code. ok is false if the
+// token is not a comment or no code follows it.
+func AnnotatedLine(tokens []lexer.Token, i int) (line int, ok bool) {
+	if tokens[i].Type != lexer.COMMENT {
+		return 0, false
+	}
+	if i > 0 && tokens[i-1].Type != lexer.COMMENT && tokens[i-1].Line == tokens[i].Line {
+		return tokens[i].Line, true
+	}
+	for _, tok := range tokens[i+1:] {
+		if tok.Type != lexer.COMMENT {
+			return tok.Line, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/bestform/shmehashme/decl"
+	"github.com/bestform/shmehashme/lexer"
+	"github.com/bestform/shmehashme/phpdoc"
+)
+
+// declaration is a named class, interface, trait, function or method
+// found by the decl package
+type declaration struct {
+	name      string
+	kind      int    // one of the Symbol constants
+	container string // the class of a method
+	nameToken int    // index of the token holding the name
+	start     int    // byte offset of the first modifier or keyword
+	end       int    // byte offset after the closing brace or semicolon
+	signature string // the declaration up to its body, with whitespace collapsed
+	doc       string // the text of the doc comment in front of the declaration
+}
+
+// symbolKinds maps the kinds of declarations to symbol kinds
+var symbolKinds = map[decl.Kind]int{
+	decl.Class:     SymbolClass,
+	decl.Interface: SymbolInterface,
+	decl.Trait:     SymbolClass,
+	decl.Function:  SymbolFunction,
+	decl.Method:    SymbolMethod,
+}
+
+func findDeclarations(src string, tokens []lexer.Token) []declaration {
+	found := decl.Find(src, tokens)
+
+	var declarations []declaration
+	for _, f := range found {
+		if f.Anonymous() {
+			continue
+		}
+
+		d := declaration{
+			name:      f.Name,
+			kind:      symbolKinds[f.Kind],
+			nameToken: f.NameToken,
+			start:     tokens[f.First].Offset,
+			end:       tokens[f.NameToken].End,
+		}
+		if f.Parent >= 0 {
+			d.container = found[f.Parent].Name
+		}
+
+		signatureEnd := d.end
+		switch {
+		case f.Body >= 0:
+			signatureEnd = tokens[f.Body].Offset
+		case f.End >= 0:
+			// the semicolon ending a declaration without body
+			signatureEnd = tokens[f.End].Offset
+		}
+		d.signature = collapse(src[d.start:signatureEnd])
+		if f.End >= 0 {
+			d.end = tokens[f.End].End
+		}
+		if f.Doc >= 0 {
+			d.doc = docText(src[tokens[f.Doc].Offset:tokens[f.Doc].End])
+		}
+
+		declarations = append(declarations, d)
+	}
+
+	return declarations
+}
+
+// collapse replaces all runs of whitespace with a single space
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// docText returns the summary, the description and the tags of a doc comment
+func docText(comment string) string {
+	doc := phpdoc.Parse(comment)
+
+	var parts []string
+	for _, text := range []string{doc.Summary, doc.Description} {
+		if text != "" {
+			parts = append(parts, text)
+		}
+	}
+	var tags []string
+	for _, tag := range doc.Tags {
+		tags = append(tags, tagText(tag))
+	}
+	if len(tags) > 0 {
+		parts = append(parts, strings.Join(tags, "\n"))
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// tagText returns a tag the way it is written in a doc comment, with its type
+// in the normalized notation
+func tagText(tag phpdoc.Tag) string {
+	parts := []string{"@" + tag.Name}
+	variable := tag.Variable
+	if tag.Variadic {
+		variable = "..." + variable
+	}
+	if tag.ByRef {
+		variable = "&" + variable
+	}
+
+	switch {
+	case tag.Type != nil && variable != "" && !strings.HasPrefix(tag.Variable, "$"):
+		// a template parameter with its bound
+		parts = append(parts, variable, "of", tag.Type.String())
+	case tag.Type != nil:
+		parts = append(parts, tag.Type.String())
+		if variable != "" {
+			parts = append(parts, variable)
+		}
+	case variable != "":
+		parts = append(parts, variable)
+	}
+	if tag.Description != "" {
+		parts = append(parts, tag.Description)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// isMember reports whether the token at i follows -> or ::
+func isMember(tokens []lexer.Token, i int) bool {
+	if i > 0 && tokens[i-1].Type == lexer.ARROW {
+		return true
+	}
+	return i > 1 && tokens[i-1].Type == lexer.COLON && tokens[i-2].Type == lexer.COLON && tokens[i-2].End == tokens[i-1].Offset
+}
+
+// shortName returns the last part of a namespaced name
+func shortName(name string) string {
+	return name[strings.LastIndex(name, `\`)+1:]
+}
@@ -0,0 +1,120 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// document is the text of an open document or a workspace file with its
+// tokens and declarations
+type document struct {
+	uri          string
+	text         string
+	tokens       []lexer.Token // without the final EOF token
+	lineStarts   []int         // byte offsets of the first character of each line
+	declarations []declaration
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	// reading from a strings.Reader does not fail
+	l, _ := lexer.New(strings.NewReader(text))
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.declarations = findDeclarations(text, d.tokens)
+
+	return d
+}
+
+// position converts a byte offset to a position
+func (d *document) position(offset int) Position {
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	prefix := d.text[d.lineStarts[line]:offset]
+	return Position{line, len(utf16.Encode([]rune(prefix)))}
+}
+
+// offset converts a position to a byte offset. Positions after the end of a
+// line or the document are moved to the end.
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[p.Line]
+	for units := 0; units < p.Character && offset < len(d.text) && !isLineEnd(d.text[offset:]); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+
+	return offset
+}
+
+func isLineEnd(s string) bool {
+	return strings.HasPrefix(s, "\n") || strings.HasPrefix(s, "\r\n")
+}
+
+func (d *document) rangeOf(start, end int) Range {
+	return Range{d.position(start), d.position(end)}
+}
+
+// lineRange returns the range of a one based line without its line ending
+func (d *document) lineRange(line int) Range {
+	if line < 1 || line > len(d.lineStarts) {
+		return Range{}
+	}
+	start := d.lineStarts[line-1]
+	end := len(d.text)
+	if line < len(d.lineStarts) {
+		end = d.lineStarts[line] - 1
+	}
+	if end > start && d.text[end-1] == '\r' {
+		end--
+	}
+
+	return d.rangeOf(start, end)
+}
+
+// tokenAt returns the index of the token at the offset, or -1 if there is
+// none. A cursor directly after a token also counts.
+func (d *document) tokenAt(offset int) int {
+	i := sort.Search(len(d.tokens), func(i int) bool { return d.tokens[i].End >= offset })
+	if i < len(d.tokens) && d.tokens[i].Offset <= offset {
+		return i
+	}
+
+	return -1
+}
+
+// pathToURI and uriToPath convert between file system paths and file URIs
+func pathToURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
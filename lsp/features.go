@@ -0,0 +1,346 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/bestform/shmehashme/highlight"
+	"github.com/bestform/shmehashme/lexer"
+	"github.com/bestform/shmehashme/lint"
+)
+
+var severities = map[lint.Severity]int{
+	lint.Error:   SeverityError,
+	lint.Warning: SeverityWarning,
+	lint.Info:    SeverityInformation,
+}
+
+func (s *Server) publishDiagnostics(d *document) error {
+	f := &lint.File{Path: uriToPath(d.uri), Source: d.text, Tokens: d.tokens}
+	found, err := lint.Lint(f, s.config)
+	if err != nil {
+		// an invalid configuration should not stop the server
+		return s.notify("window/showMessage", map[string]interface{}{"type": SeverityError, "message": err.Error()})
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, diag := range found {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.lineRange(diag.Line),
+			Severity: severities[diag.Severity],
+			Code:     diag.Rule,
+			Source:   "shmehashme",
+			Message:  diag.Message,
+		})
+	}
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{d.uri, diagnostics})
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []DocumentSymbol{}
+	for _, decl := range d.declarations {
+		name := d.tokens[decl.nameToken]
+		symbol := DocumentSymbol{
+			Name:           decl.name,
+			Detail:         decl.signature,
+			Kind:           decl.kind,
+			Range:          d.rangeOf(decl.start, decl.end),
+			SelectionRange: d.rangeOf(name.Offset, name.End),
+		}
+
+		// methods are nested in the last class with the same name
+		if decl.kind == SymbolMethod {
+			for i := len(symbols) - 1; i >= 0; i-- {
+				if symbols[i].Name == decl.container && symbols[i].Kind != SymbolFunction {
+					symbols[i].Children = append(symbols[i].Children, symbol)
+					break
+				}
+			}
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols, nil
+}
+
+// target is what the cursor is on
+type target struct {
+	doc   *document
+	token int    // index of the token under the cursor
+	name  string // the variable with "$" or the short name of the identifier
+}
+
+func (s *Server) targetAt(p TextDocumentPositionParams) (*target, error) {
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	i := d.tokenAt(d.offset(p.Position))
+	if i < 0 {
+		return nil, nil
+	}
+	tok := d.tokens[i]
+	switch {
+	case tok.Type == lexer.VAR:
+		return &target{d, i, tok.Literal}, nil
+	case tok.Type == lexer.IDENT:
+		return &target{d, i, shortName(tok.Literal)}, nil
+	}
+
+	return nil, nil
+}
+
+// scope returns the byte range of the innermost function around the token,
+// or the whole document for tokens outside of functions
+func (t *target) scope() (int, int) {
+	offset := t.doc.tokens[t.token].Offset
+	start, end := 0, len(t.doc.text)
+	for _, decl := range t.doc.declarations {
+		isFunction := decl.kind == SymbolFunction || decl.kind == SymbolMethod
+		if isFunction && decl.start <= offset && offset < decl.end && decl.start >= start {
+			start, end = decl.start, decl.end
+		}
+	}
+
+	return start, end
+}
+
+// inScope reports whether the token at i of the target's document is in the
+// same scope as the target. Variables of nested functions are excluded.
+func (t *target) inScope(i int) bool {
+	start, end := t.scope()
+	offset := t.doc.tokens[i].Offset
+	if offset < start || offset >= end {
+		return false
+	}
+	for _, decl := range t.doc.declarations {
+		isFunction := decl.kind == SymbolFunction || decl.kind == SymbolMethod
+		if isFunction && decl.start > start && decl.start <= offset && offset < decl.end {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matches reports whether a declaration can be what the identifier at the target refers to
+func (t *target) matches(decl declaration) bool {
+	if !strings.EqualFold(decl.name, t.name) {
+		return false
+	}
+
+	tokens := t.doc.tokens
+	i := t.token
+	for _, own := range t.doc.declarations {
+		if own.nameToken == i {
+			// the cursor is on the name of a declaration
+			return own.kind == decl.kind
+		}
+	}
+
+	isCall := i+1 < len(tokens) && tokens[i+1].Type == lexer.LPAREN
+	isNew := i > 0 && strings.EqualFold(tokens[i-1].Literal, "new")
+	switch {
+	case isMember(tokens, i):
+		return decl.kind == SymbolMethod
+	case isCall && !isNew:
+		return decl.kind == SymbolFunction
+	default:
+		return decl.kind != SymbolFunction && decl.kind != SymbolMethod
+	}
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.targetAt(p)
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	if t.doc.tokens[t.token].Type == lexer.VAR {
+		// the first use of a variable in its scope is taken as its definition
+		for i, tok := range t.doc.tokens {
+			if tok.Type == lexer.VAR && tok.Literal == t.name && t.inScope(i) {
+				locations = append(locations, Location{t.doc.uri, t.doc.rangeOf(tok.Offset, tok.End)})
+				break
+			}
+		}
+		return locations, nil
+	}
+
+	for _, d := range s.workspace() {
+		for _, decl := range d.declarations {
+			if t.matches(decl) {
+				name := d.tokens[decl.nameToken]
+				locations = append(locations, Location{d.uri, d.rangeOf(name.Offset, name.End)})
+			}
+		}
+	}
+
+	return locations, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.targetAt(p.TextDocumentPositionParams)
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	if t.doc.tokens[t.token].Type == lexer.VAR {
+		for i, tok := range t.doc.tokens {
+			if tok.Type == lexer.VAR && tok.Literal == t.name && t.inScope(i) {
+				locations = append(locations, Location{t.doc.uri, t.doc.rangeOf(tok.Offset, tok.End)})
+			}
+		}
+		return locations, nil
+	}
+
+	// identifiers are matched by name, as classes and functions are case insensitive
+	for _, d := range s.workspace() {
+		declared := map[int]bool{}
+		for _, decl := range d.declarations {
+			declared[decl.nameToken] = true
+		}
+		for i, tok := range d.tokens {
+			if tok.Type != lexer.IDENT || !strings.EqualFold(shortName(tok.Literal), t.name) {
+				continue
+			}
+			if declared[i] && !p.Context.IncludeDeclaration {
+				continue
+			}
+			locations = append(locations, Location{d.uri, d.rangeOf(tok.Offset, tok.End)})
+		}
+	}
+
+	return locations, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	t, err := s.targetAt(p)
+	if err != nil || t == nil || t.doc.tokens[t.token].Type != lexer.IDENT {
+		return nil, err
+	}
+
+	var parts []string
+	for _, d := range s.workspace() {
+		for _, decl := range d.declarations {
+			if !t.matches(decl) {
+				continue
+			}
+			part := "```php\n" + decl.signature + "\n```"
+			if decl.container != "" {
+				part += "\n\nin " + decl.container
+			}
+			if decl.doc != "" {
+				part += "\n\n" + decl.doc
+			}
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	tok := t.doc.tokens[t.token]
+	r := t.doc.rangeOf(tok.Offset, tok.End)
+	return Hover{MarkupContent{"markdown", strings.Join(parts, "\n\n---\n\n")}, &r}, nil
+}
+
+// semanticTokenTypes is the legend of the semantic tokens. The index of a
+// type is the highlight category minus one, as plain tokens are left out.
+var semanticTokenTypes = []string{"keyword", "variable", "string", "number", "comment", "operator"}
+
+func (s *Server) semanticTokens(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []int{}
+	var last Position
+	for _, tok := range d.tokens {
+		category := highlight.CategoryOf(tok.Type)
+		if category == highlight.Plain {
+			continue
+		}
+
+		// tokens spanning several lines are split, as not all clients support them
+		start := tok.Offset
+		for _, line := range strings.SplitAfter(d.text[tok.Offset:tok.End], "\n") {
+			text := strings.TrimRight(line, "\r\n")
+			if text != "" {
+				pos := d.position(start)
+				deltaStart := pos.Character
+				if pos.Line == last.Line {
+					deltaStart -= last.Character
+				}
+				length := len(utf16.Encode([]rune(text)))
+				data = append(data, pos.Line-last.Line, deltaStart, length, int(category)-1, 0)
+				last = pos
+			}
+			start += len(line)
+		}
+	}
+
+	return SemanticTokens{data}, nil
+}
+
+// formatting applies the fixes of the lint rules. There is no formatter yet
+// that rewrites the layout of the code as a whole.
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		TextDocument TextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &lint.File{Path: uriToPath(d.uri), Source: d.text, Tokens: d.tokens}
+	fixed, _, err := lint.Fix(f, s.config)
+	if err != nil {
+		return nil, err
+	}
+	if fixed.Source == d.text {
+		return []TextEdit{}, nil
+	}
+
+	return []TextEdit{{d.rangeOf(0, len(d.text)), fixed.Source}}, nil
+}
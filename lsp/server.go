@@ -0,0 +1,276 @@
+// Package lsp implements a language server for PHP speaking the Language
+// Server Protocol over a stream, usually stdin and stdout of the process.
+//
+// There is no parser yet, so all features work on the token stream:
+// diagnostics come from the lint rules, declarations are found by their
+// keywords and definitions and references are matched by name.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/bestform/shmehashme/lint"
+	"github.com/bestform/shmehashme/scan"
+)
+
+// ErrExitWithoutShutdown is returned by Serve if the client sends exit before shutdown
+var ErrExitWithoutShutdown = errors.New("exit without shutdown request")
+
+// Server is a language server. Create it with NewServer.
+type Server struct {
+	config      *lint.Config
+	out         io.Writer
+	initialized bool
+	shutdown    bool
+	root        string               // directory of the workspace, empty if there is none
+	paths       []string             // PHP files in the workspace
+	open        map[string]*document // documents opened by the client by URI
+	files       map[string]*document // workspace files read from disk by URI
+}
+
+// NewServer returns a server that lints with cfg. A nil cfg enables all
+// rules with their default settings.
+func NewServer(cfg *lint.Config) *Server {
+	return &Server{
+		config: cfg,
+		open:   map[string]*document{},
+		files:  map[string]*document{},
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var requests = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"shutdown":                         (*Server).shutdownRequest,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/references":          (*Server).references,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+	"textDocument/formatting":          (*Server).formatting,
+}
+
+var notifications = map[string]func(s *Server, params json.RawMessage) error{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Serve reads messages from r and writes responses and notifications to w
+// until the client sends the exit notification
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.out = w
+	in := bufio.NewReader(r)
+
+	for {
+		body, err := readMessage(in)
+		if err == io.EOF && s.shutdown {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			null := json.RawMessage("null")
+			if err := s.reply(&null, nil, &responseError{codeParseError, err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if req.ID == nil {
+			// unknown notifications like $/cancelRequest may be ignored
+			if notify, ok := notifications[req.Method]; ok && s.initialized && !s.shutdown {
+				if err := notify(s, req.Params); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		result, err := s.handle(req)
+		var rerr *responseError
+		if err != nil && !errors.As(err, &rerr) {
+			rerr = &responseError{codeInternalError, err.Error()}
+		}
+		if err := s.reply(req.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) (interface{}, error) {
+	h, ok := requests[req.Method]
+	switch {
+	case !ok:
+		return nil, &responseError{codeMethodNotFound, "unknown method " + req.Method}
+	case !s.initialized && req.Method != "initialize":
+		return nil, &responseError{codeServerNotInitialized, "server not initialized"}
+	case s.shutdown:
+		return nil, &responseError{codeInvalidRequest, "server is shutting down"}
+	}
+
+	return h(s, req.Params)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	resp := response{JSONRPC: "2.0", ID: id, Error: rerr}
+	if rerr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		r := json.RawMessage(raw)
+		resp.Result = &r
+	}
+
+	return writeMessage(s.out, resp)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{"2.0", method, params})
+}
+
+// decode unmarshals the parameters of a request
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{codeInvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p InitializeParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.RootURI != "":
+		s.root = uriToPath(p.RootURI)
+	case len(p.WorkspaceFolders) > 0:
+		s.root = uriToPath(p.WorkspaceFolders[0].URI)
+	default:
+		s.root = p.RootPath
+	}
+	if s.root != "" {
+		// without a readable workspace the server still works on open documents
+		s.paths, _ = scan.Walk(s.root, scan.Options{})
+	}
+	s.initialized = true
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           map[string]interface{}{"openClose": true, "change": 1},
+			"documentSymbolProvider":     true,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"hoverProvider":              true,
+			"documentFormattingProvider": true,
+			"semanticTokensProvider": map[string]interface{}{
+				"legend": map[string]interface{}{"tokenTypes": semanticTokenTypes, "tokenModifiers": []string{}},
+				"full":   true,
+			},
+		},
+		"serverInfo": map[string]string{"name": "shmehashme"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	s.open[p.TextDocument.URI] = newDocument(p.TextDocument.URI, p.TextDocument.Text)
+
+	return s.publishDiagnostics(s.open[p.TextDocument.URI])
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	// the server asked for full document changes, so the last one holds the whole text
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	s.open[p.TextDocument.URI] = newDocument(p.TextDocument.URI, text)
+
+	return s.publishDiagnostics(s.open[p.TextDocument.URI])
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	delete(s.open, p.TextDocument.URI)
+	// the file may have been saved with changes
+	delete(s.files, p.TextDocument.URI)
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{p.TextDocument.URI, []Diagnostic{}})
+}
+
+// document returns the open document or the workspace file with the URI
+func (s *Server) document(uri string) (*document, error) {
+	if d, ok := s.open[uri]; ok {
+		return d, nil
+	}
+	if d, ok := s.files[uri]; ok {
+		return d, nil
+	}
+
+	src, err := ioutil.ReadFile(uriToPath(uri))
+	if err != nil {
+		return nil, &responseError{codeInvalidParams, "unknown document " + uri}
+	}
+	s.files[uri] = newDocument(uri, string(src))
+
+	return s.files[uri], nil
+}
+
+// workspace returns all open documents and workspace files ordered by URI.
+// Files that cannot be read are skipped.
+func (s *Server) workspace() []*document {
+	uris := map[string]bool{}
+	for uri := range s.open {
+		uris[uri] = true
+	}
+	for _, path := range s.paths {
+		uris[pathToURI(path)] = true
+	}
+
+	var sorted []string
+	for uri := range uris {
+		sorted = append(sorted, uri)
+	}
+	sort.Strings(sorted)
+
+	var docs []*document
+	for _, uri := range sorted {
+		if d, err := s.document(uri); err == nil {
+			docs = append(docs, d)
+		}
+	}
+
+	return docs
+}
@@ -0,0 +1,458 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bestform/shmehashme/lint"
+)
+
+// client talks to a server running in the same process
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	messages chan map[string]json.RawMessage
+	done     chan error
+	nextID   int
+	// notifications received while waiting for responses
+	notifications []map[string]json.RawMessage
+}
+
+func startServer(t *testing.T) *client {
+	return startServerWithConfig(t, nil)
+}
+
+func startServerWithConfig(t *testing.T, cfg *lint.Config) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{
+		t:        t,
+		w:        inW,
+		messages: make(chan map[string]json.RawMessage, 100),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer(cfg).Serve(inR, outW)
+		outW.Close()
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			body, err := readMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("invalid message from server: %s", body)
+			}
+			c.messages <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) next() map[string]json.RawMessage {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout waiting for the server")
+	}
+	return nil
+}
+
+func (c *client) send(v interface{}) {
+	if err := writeMessage(c.w, v); err != nil {
+		c.t.Fatal("error sending message", err)
+	}
+}
+
+// call sends a request and decodes the result of the response into result.
+// It returns the error of the response.
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+
+	for {
+		msg := c.next()
+		if _, ok := msg["id"]; !ok {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(msg["id"]) != fmt.Sprint(id) {
+			c.t.Fatalf("unexpected response %s for request %d", msg["id"], id)
+		}
+		if msg["error"] != nil {
+			var rerr responseError
+			json.Unmarshal(msg["error"], &rerr)
+			return &rerr
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg["result"], result); err != nil {
+				c.t.Fatalf("error decoding result of %s: %v", method, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// waitFor returns the params of the next notification with the given method
+func (c *client) waitFor(method string, params interface{}) {
+	for i, msg := range c.notifications {
+		if string(msg["method"]) == fmt.Sprintf("%q", method) {
+			c.notifications = append(c.notifications[:i], c.notifications[i+1:]...)
+			json.Unmarshal(msg["params"], params)
+			return
+		}
+	}
+	for {
+		msg := c.next()
+		if string(msg["method"]) == fmt.Sprintf("%q", method) {
+			json.Unmarshal(msg["params"], params)
+			return
+		}
+		c.notifications = append(c.notifications, msg)
+	}
+}
+
+func (c *client) stop() {
+	if err := c.call("shutdown", nil, nil); err != nil {
+		c.t.Fatal("shutdown failed", err)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Fatal("server failed", err)
+	}
+}
+
+func initialize(t *testing.T) (*client, string) {
+	root, err := filepath.Abs("testdata/workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := startServer(t)
+	var result struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := c.call("initialize", map[string]interface{}{"rootUri": pathToURI(root)}, &result); err != nil {
+		t.Fatal("initialize failed", err)
+	}
+	if result.Capabilities["definitionProvider"] != true {
+		t.Fatalf("unexpected capabilities %v", result.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	return c, root
+}
+
+func TestLifecycle(t *testing.T) {
+	c := startServer(t)
+	if err := c.call("textDocument/hover", map[string]interface{}{}, nil); err == nil || err.Code != codeServerNotInitialized {
+		t.Errorf("expected error %d before initialize, got %v", codeServerNotInitialized, err)
+	}
+	if err := c.call("initialize", map[string]interface{}{}, nil); err != nil {
+		t.Fatal("initialize failed", err)
+	}
+	if err := c.call("textDocument/unknown", map[string]interface{}{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected error %d for an unknown method, got %v", codeMethodNotFound, err)
+	}
+	c.stop()
+
+	c = startServer(t)
+	c.notify("exit", nil)
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected %v, got %v", ErrExitWithoutShutdown, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c, _ := initialize(t)
+	defer c.stop()
+
+	uri := "file:///tmp/test.php"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "php", Version: 1, Text: "<?php\n$a = 1 # 2;\n"},
+	})
+	var published PublishDiagnosticsParams
+	c.waitFor("textDocument/publishDiagnostics", &published)
+	expected := []Diagnostic{{Range{Position{1, 0}, Position{1, 11}}, SeverityError, "illegal-character", "shmehashme", `illegal character "#"`}}
+	if published.URI != uri || !reflect.DeepEqual(published.Diagnostics, expected) {
+		t.Errorf("expected %v, got %v", expected, published)
+	}
+
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []TextDocumentContentChangeEvent{{"<?php\n$a = 1;\n"}},
+	})
+	c.waitFor("textDocument/publishDiagnostics", &published)
+	if len(published.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics after the change, got %v", published.Diagnostics)
+	}
+}
+
+func TestDiagnosticsWithConfig(t *testing.T) {
+	// the workspace is not the working directory of the server
+	root, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	config := `{"overrides": [{"directory": "legacy", "rules": {"loose-comparison": {"enabled": false}}}]}`
+	if err := ioutil.WriteFile(filepath.Join(root, "lint.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := lint.LoadConfig(filepath.Join(root, "lint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := startServerWithConfig(t, cfg)
+	defer c.stop()
+	if err := c.call("initialize", map[string]interface{}{"rootUri": pathToURI(root)}, nil); err != nil {
+		t.Fatal("initialize failed", err)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"legacy/old.php", 0},
+		{"new.php", 1},
+	}
+	for _, tt := range tests {
+		uri := pathToURI(filepath.Join(root, tt.path))
+		c.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": TextDocumentItem{URI: uri, LanguageID: "php", Version: 1, Text: "<?php\n$a == 1;\n"},
+		})
+		var published PublishDiagnosticsParams
+		c.waitFor("textDocument/publishDiagnostics", &published)
+		if published.URI != uri || len(published.Diagnostics) != tt.expected {
+			t.Errorf("%s: expected %d diagnostics, got %v", tt.path, tt.expected, published)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	c, root := initialize(t)
+	defer c.stop()
+
+	var symbols []DocumentSymbol
+	uri := pathToURI(filepath.Join(root, "User.php"))
+	if err := c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": TextDocumentIdentifier{uri}}, &symbols); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []DocumentSymbol{{
+		Name:           "User",
+		Detail:         "class User extends Model",
+		Kind:           SymbolClass,
+		Range:          Range{Position{5, 0}, Position{17, 1}},
+		SelectionRange: Range{Position{5, 6}, Position{5, 10}},
+		Children: []DocumentSymbol{{
+			Name:           "displayName",
+			Detail:         "public function displayName(string $prefix): string",
+			Kind:           SymbolMethod,
+			Range:          Range{Position{12, 4}, Position{16, 5}},
+			SelectionRange: Range{Position{12, 20}, Position{12, 31}},
+		}},
+	}}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("expected %+v, got %+v", expected, symbols)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c, root := initialize(t)
+	defer c.stop()
+
+	user := pathToURI(filepath.Join(root, "User.php"))
+	functions := pathToURI(filepath.Join(root, "functions.php"))
+	at := func(uri string, line, character int) TextDocumentPositionParams {
+		return TextDocumentPositionParams{TextDocumentIdentifier{uri}, Position{line, character}}
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		params   interface{}
+		expected []Location
+	}{
+		{"function in other file", "textDocument/definition", at(user, 15, 16), []Location{
+			{functions, Range{Position{3, 9}, Position{3, 20}}},
+		}},
+		{"method", "textDocument/definition", at(functions, 10, 30), []Location{
+			{user, Range{Position{12, 20}, Position{12, 31}}},
+		}},
+		{"class in type hint", "textDocument/definition", at(functions, 8, 16), []Location{
+			{user, Range{Position{5, 6}, Position{5, 10}}},
+		}},
+		{"variable", "textDocument/definition", at(user, 15, 28), []Location{
+			{user, Range{Position{14, 8}, Position{14, 13}}},
+		}},
+		{"function references", "textDocument/references", ReferenceParams{TextDocumentPositionParams: at(functions, 3, 12)}, []Location{
+			{user, Range{Position{15, 15}, Position{15, 26}}},
+			{functions, Range{Position{10, 47}, Position{10, 58}}},
+		}},
+		{"variable references", "textDocument/references", ReferenceParams{TextDocumentPositionParams: at(functions, 5, 20)}, []Location{
+			{functions, Range{Position{3, 21}, Position{3, 26}}},
+			{functions, Range{Position{5, 19}, Position{5, 24}}},
+		}},
+	}
+
+	for _, tt := range tests {
+		var locations []Location
+		if err := c.call(tt.method, tt.params, &locations); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(locations, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, locations)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c, root := initialize(t)
+	defer c.stop()
+
+	var hover Hover
+	params := TextDocumentPositionParams{TextDocumentIdentifier{pathToURI(filepath.Join(root, "functions.php"))}, Position{10, 30}}
+	if err := c.call("textDocument/hover", params, &hover); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "```php\npublic function displayName(string $prefix): string\n```\n\nin User\n\nReturns the name shown to other users"
+	if hover.Contents.Value != expected {
+		t.Errorf("expected %q, got %q", expected, hover.Contents.Value)
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	c, _ := initialize(t)
+	defer c.stop()
+
+	uri := "file:///tmp/tokens.php"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "php", Version: 1, Text: "<?php\n$ä = 'x';\n/* a\nb */"},
+	})
+	var tokens SemanticTokens
+	if err := c.call("textDocument/semanticTokens/full", map[string]interface{}{"textDocument": TextDocumentIdentifier{uri}}, &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{
+		0, 0, 5, 0, 0, // <?php
+		1, 0, 2, 1, 0, // $ä
+		0, 3, 1, 5, 0, // =
+		0, 2, 3, 2, 0, // 'x'
+		1, 0, 4, 4, 0, // /* a
+		1, 0, 4, 4, 0, // b */
+	}
+	if !reflect.DeepEqual(tokens.Data, expected) {
+		t.Errorf("expected %v, got %v", expected, tokens.Data)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	c, _ := initialize(t)
+	defer c.stop()
+
+	uri := "file:///tmp/format.php"
+	text := "<?php\nIF ($a) {  \n}\n"
+	c.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": TextDocumentItem{URI: uri, LanguageID: "php", Version: 1, Text: text},
+	})
+	var edits []TextEdit
+	if err := c.call("textDocument/formatting", map[string]interface{}{"textDocument": TextDocumentIdentifier{uri}}, &edits); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TextEdit{{Range{Position{0, 0}, Position{3, 0}}, "<?php\nif ($a) {\n}\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("expected %v, got %v", expected, edits)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	d := newDocument("file:///tmp/a.php", "a\r\n😀b\nc")
+	tests := []struct {
+		offset   int
+		position Position
+	}{
+		{0, Position{0, 0}},
+		{3, Position{1, 0}},
+		{7, Position{1, 2}}, // the emoji takes two UTF-16 code units
+		{8, Position{1, 3}},
+		{9, Position{2, 0}},
+		{10, Position{2, 1}},
+	}
+	for _, tt := range tests {
+		if p := d.position(tt.offset); p != tt.position {
+			t.Errorf("position(%d): expected %v, got %v", tt.offset, tt.position, p)
+		}
+		if o := d.offset(tt.position); o != tt.offset {
+			t.Errorf("offset(%v): expected %d, got %d", tt.position, tt.offset, o)
+		}
+	}
+	if o := d.offset(Position{0, 10}); o != 1 {
+		t.Errorf("expected positions after the end of a line to be moved to the end, got %d", o)
+	}
+	if !strings.HasPrefix(pathToURI("/tmp/a b.php"), "file:///tmp/a%20b.php") || uriToPath("file:///tmp/a%20b.php") != "/tmp/a b.php" {
+		t.Error("unexpected conversion between paths and URIs")
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{"Content-Length: 2\r\n\r\n{}", "{}", ""},
+		{"Content-Length: 0\r\n\r\n", "", ""},
+		{"Content-Length: x\r\n\r\n", "", `invalid Content-Length "x"`},
+		{"Content-Length: -1\r\n\r\n", "", "invalid Content-Length -1, expected 0 to 67108864 bytes"},
+		{"Content-Length: 67108865\r\n\r\n", "", "invalid Content-Length 67108865, expected 0 to 67108864 bytes"},
+	}
+	for _, tt := range tests {
+		body, err := readMessage(bufio.NewReader(strings.NewReader(tt.input)))
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%q: expected error %q, got %v", tt.input, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || string(body) != tt.expected {
+			t.Errorf("%q: expected %q, got %q and %v", tt.input, tt.expected, body, err)
+		}
+	}
+}
+
+func TestDocText(t *testing.T) {
+	comment := "/**\n * Finds users.\n *\n * Leaves out inactive ones.\n * @param array<int,Group> &$groups the groups\n * @template T of User\n * @return list<T>\n */"
+	expected := "Finds users.\n\nLeaves out inactive ones.\n\n@param array<int, Group> &$groups the groups\n@template T of User\n@return list<T>"
+	if text := docText(comment); text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+}
@@ -0,0 +1,18 @@
+<?php
+
+/**
+ * A user of the application
+ */
+class User extends Model
+{
+    private $name;
+
+    /**
+     * Returns the name shown to other users
+     */
+    public function displayName(string $prefix): string
+    {
+        $name = $prefix . $this->name;
+        return format_name($name);
+    }
+}
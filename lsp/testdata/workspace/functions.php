@@ -0,0 +1,12 @@
+<?php
+
+/** Formats a name for display */
+function format_name($name)
+{
+    return ucfirst($name);
+}
+
+function greet(User $user)
+{
+    return "Hello " . $user->displayName("") . format_name("!");
+}
@@ -1,50 +1,71 @@
 package main
 
 import (
-	"flag"
 	"fmt"
+	"io"
 	"os"
+)
 
-	"github.com/bestform/shmehashme/lexer"
-	"github.com/bestform/shmehashme/repl"
+const (
+	exitOK       = 0 // everything went fine
+	exitFindings = 1 // the command ran but found problems in the input
+	exitError    = 2 // wrong usage or the input could not be read
 )
 
-func main() {
-	fmt.Println("Why hello there! This a REPL for the shmehashme PHP lexer")
-	fmt.Println("Feel free to type in commands")
-	repl.Start(os.Stdin, os.Stdout)
+// command is a subcommand of the shmehashme tool
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
 }
 
-func mainFile() {
-	file := flag.String("file", "", "File to lex")
-	flag.Parse()
+var commands []command
 
-	if *file == "" {
-		fmt.Println("Please specify a file")
-		os.Exit(1)
+func init() {
+	commands = []command{
+		{"lex", "print the tokens of PHP source code", runLex},
+		{"check", "report problems found by the lint rules", runCheck},
+		{"highlight", "print source code with syntax highlighting", runHighlight},
+		{"metrics", "report size and complexity metrics", runMetrics},
+		{"repl", "start an interactive lexer session", runRepl},
+		{"lsp", "run a language server on stdin and stdout", runLsp},
 	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	f, err := os.Open(*file)
-	defer f.Close()
-	if err != nil {
-		fmt.Println("Error opening file: ", err)
-		os.Exit(1)
+// run executes the subcommand named by the first argument and returns the exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitError
 	}
-	l, err := lexer.New(f)
-	if err != nil {
-		fmt.Println("Error creating lexer: ", err)
-		os.Exit(1)
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		usage(stdout)
+		return exitOK
 	}
-	var tokens []lexer.Token
-	var t lexer.Token
-	for {
-		t = l.NextToken()
-		if t.Type == lexer.EOF {
-			break
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
 		}
-		tokens = append(tokens, t)
 	}
 
-	o := lexer.PrettyPrint(tokens)
-	fmt.Print(o)
+	fmt.Fprintf(stderr, "Unknown command %q\n\n", args[0])
+	usage(stderr)
+	return exitError
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: shmehashme <command> [flags] [file|directory|glob|-]...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s%s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a file argument (or with \"-\") the source is read from stdin.")
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var cliTests = []struct {
+	golden   string
+	args     []string
+	stdin    string
+	exitCode int
+}{
+	{"lex_text", []string{"lex", "testdata/src/hello.php"}, "", exitOK},
+	{"lex_json", []string{"lex", "-format=json", "testdata/src/hello.php"}, "", exitOK},
+	{"lex_directory", []string{"lex", "testdata/src"}, "", exitOK},
+	{"lex_json_directory", []string{"lex", "-format=json", "testdata/src"}, "", exitOK},
+	{"lex_exclude", []string{"lex", "-exclude=sub/", "-exclude", "*.phtml", "testdata/src"}, "", exitOK},
+	{"lex_extensions", []string{"lex", "-ext=.phtml", "testdata/src"}, "", exitOK},
+	{"lex_glob", []string{"lex", "testdata/src/*.php"}, "", exitOK},
+	{"lex_glob_no_match", []string{"lex", "testdata/nomatch/*.php"}, "", exitError},
+	{"lex_stdin", []string{"lex"}, "<?php $a <=> $b;", exitOK},
+	{"lex_php_tokens", []string{"lex", "-format=php-tokens", "testdata/src/hello.php"}, "", exitOK},
+	{"lex_php_tokens_ids", []string{"lex", "-format=php-tokens", "-token-ids=testdata/token_ids.json"}, "<?php $a;", exitOK},
+	{"lex_php_tokens_missing_id", []string{"lex", "-format=php-tokens", "-token-ids=testdata/token_ids.json"}, "<?php 1;", exitError},
+	{"lex_unknown_format", []string{"lex", "-format=xml", "testdata/src/hello.php"}, "", exitError},
+	{"lex_missing_file", []string{"lex", "testdata/src/missing.php"}, "", exitError},
+	{"check_clean", []string{"check", "testdata/src/hello.php"}, "", exitOK},
+	{"check_illegal", []string{"check", "testdata/src"}, "", exitFindings},
+	{"check_config", []string{"check", "-config=testdata/lint.json", "testdata/src"}, "", exitOK},
+	{"check_bad_config", []string{"check", "-config=testdata/missing.json", "testdata/src"}, "", exitError},
+	{"check_diff", []string{"check", "-diff", "testdata/fix"}, "", exitFindings},
+	{"check_diff_clean", []string{"check", "-diff", "testdata/src/hello.php"}, "", exitOK},
+	{"check_fix_stdin", []string{"check", "-fix"}, "<?php\nIF (true) {}\n", exitError},
+	{"check_fix_and_diff", []string{"check", "-fix", "-diff", "testdata/fix"}, "", exitError},
+	{"check_progress", []string{"check", "-jobs=1", "-progress", "testdata/src"}, "", exitFindings},
+	{"metrics_text", []string{"metrics", "testdata/src"}, "", exitOK},
+	{"metrics_csv", []string{"metrics", "-format=csv", "testdata/src/hello.php"}, "", exitOK},
+	{"metrics_json", []string{"metrics", "-format=json", "-"}, "<?php\nclass A {\n  function b($c) {\n    return $c ?: 1;\n  }\n}\n", exitOK},
+	{"metrics_progress", []string{"metrics", "-jobs=1", "-progress", "testdata/src"}, "", exitOK},
+	{"metrics_thresholds", []string{"metrics", "-max-params=0", "-max-lines=2", "testdata/src/hello.php"}, "", exitFindings},
+	{"highlight_ansi", []string{"highlight", "testdata/src/hello.php"}, "", exitOK},
+	{"highlight_html", []string{"highlight", "-format=html", "-line-numbers", "testdata/src/hello.php"}, "", exitOK},
+	{"highlight_svg", []string{"highlight", "-format=svg", "-"}, "<?php $a = 1;", exitOK},
+	{"highlight_css", []string{"highlight", "-format=css"}, "", exitOK},
+	{"repl", []string{"repl", "-history="}, "$a = 1;\nfoo();\n", exitOK},
+	{"repl_multiline", []string{"repl", "-history="}, "function foo() {\n  return 1;\n}\n:json\n$a;\n:load testdata/src/view.phtml\n:quit\n$b;\n", exitOK},
+	{"lsp", []string{"lsp"}, lspSession(
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.php","languageId":"php","version":1,"text":"<?php\n$a == 1;\n"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	), exitOK},
+	{"lsp_exit_without_shutdown", []string{"lsp"}, lspSession(`{"jsonrpc":"2.0","method":"exit"}`), exitError},
+	{"help", []string{"help"}, "", exitOK},
+	{"no_command", []string{}, "", exitError},
+	{"unknown_command", []string{"frobnicate"}, "", exitError},
+}
+
+// lspSession frames the messages for the lsp command
+func lspSession(messages ...string) string {
+	var b strings.Builder
+	for _, m := range messages {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(m), m)
+	}
+	return b.String()
+}
+
+func TestCommands(t *testing.T) {
+
+	for _, tt := range cliTests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+		if code != tt.exitCode {
+			t.Errorf("%v: expected exit code %d, got %d (stderr: %s)", tt.golden, tt.exitCode, code, stderr.String())
+		}
+
+		output := stdout.String()
+		if stderr.Len() > 0 {
+			output += "--- stderr ---\n" + stderr.String()
+		}
+
+		golden := filepath.Join("testdata", tt.golden+".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(output), 0644); err != nil {
+				t.Fatal("error writing golden file", err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal("error reading golden file", err)
+		}
+		if output != string(expected) {
+			t.Errorf("%v: output does not match golden file:\nEXPECTED:\n%s\n\nACTUAL:\n%s", tt.golden, expected, output)
+		}
+	}
+
+}
+
+func TestCheckFix(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "shmehashme")
+	if err != nil {
+		t.Fatal("error creating temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := ioutil.ReadFile("testdata/fix/keywords.php")
+	if err != nil {
+		t.Fatal("error reading test fixture", err)
+	}
+	file := filepath.Join(dir, "keywords.php")
+	if err := ioutil.WriteFile(file, src, 0644); err != nil {
+		t.Fatal("error writing file", err)
+	}
+
+	expected := "<?php\n\nif ($a == true) {\n    return null;\n}\n"
+	for i := 0; i < 2; i++ {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"check", "-fix", file}, strings.NewReader(""), &stdout, &stderr)
+		if code != exitFindings {
+			t.Fatalf("run %d - expected exit code %d, got %d (stderr: %s)", i, exitFindings, code, stderr.String())
+		}
+		expectedOutput := file + ":3: warning: use === instead of == (loose-comparison)\n"
+		if stdout.String() != expectedOutput {
+			t.Fatalf("run %d - expected output %q, got %q", i, expectedOutput, stdout.String())
+		}
+
+		fixed, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal("error reading fixed file", err)
+		}
+		if string(fixed) != expected {
+			t.Fatalf("run %d - fixed file wrong. expected=%q, got=%q", i, expected, fixed)
+		}
+	}
+
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import "fmt"
+
+// Thresholds are the highest values of the metrics that are considered fine.
+// A zero value disables the check for that metric.
+type Thresholds struct {
+	Cyclomatic int // per function
+	Cognitive  int // per function
+	Nesting    int // per function
+	Params     int // per function
+	Lines      int // physical lines per function
+	Methods    int // per class
+}
+
+// Diagnostic reports a metric that is above its threshold
+type Diagnostic struct {
+	Path    string
+	Line    int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.Path, d.Line, d.Message)
+}
+
+// Check returns a diagnostic for every metric of f above its threshold
+func (f File) Check(t Thresholds) []Diagnostic {
+	var diagnostics []Diagnostic
+	report := func(line int, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{f.Path, line, fmt.Sprintf(format, args...)})
+	}
+
+	for _, c := range f.Classes {
+		if t.Methods > 0 && c.Methods > t.Methods {
+			report(c.Line, "class %s has %d methods (max %d)", c.Name, c.Methods, t.Methods)
+		}
+	}
+
+	for _, fn := range f.Functions {
+		name := "function " + fn.Name
+		if fn.Class != "" {
+			name = "method " + fn.Class + "::" + fn.Name
+		}
+
+		if t.Cyclomatic > 0 && fn.Cyclomatic > t.Cyclomatic {
+			report(fn.Line, "%s has a cyclomatic complexity of %d (max %d)", name, fn.Cyclomatic, t.Cyclomatic)
+		}
+		if t.Cognitive > 0 && fn.Cognitive > t.Cognitive {
+			report(fn.Line, "%s has a cognitive complexity of %d (max %d)", name, fn.Cognitive, t.Cognitive)
+		}
+		if t.Nesting > 0 && fn.Nesting > t.Nesting {
+			report(fn.Line, "%s is nested %d levels deep (max %d)", name, fn.Nesting, t.Nesting)
+		}
+		if t.Params > 0 && fn.Params > t.Params {
+			report(fn.Line, "%s has %d parameters (max %d)", name, fn.Params, t.Params)
+		}
+		if t.Lines > 0 && fn.Lines.Physical > t.Lines {
+			report(fn.Line, "%s has %d lines (max %d)", name, fn.Lines.Physical, t.Lines)
+		}
+	}
+
+	return diagnostics
+}
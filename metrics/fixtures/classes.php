@@ -0,0 +1,34 @@
+<?php
+
+interface Greeter {
+    public function greet($name);
+}
+
+class Foo implements Greeter {
+    /**
+     * Greets someone
+     */
+    public function greet($name) {
+        $greet = function ($n) use ($name) {
+            return "Hello " . $n;
+        };
+        return $greet($name);
+    }
+
+    private static function factorial($n) {
+        return $n <= 1 ? 1 : $n * self::factorial($n - 1);
+    }
+}
+
+$name = Foo::class;
+$anon = new class {
+    public function run() {
+        do {
+            $i++;
+        } while ($i < 10);
+    }
+};
+
+function fib($n) {
+    return $n < 2 ? $n : fib($n - 1) + fib($n - 2);
+}
@@ -0,0 +1,34 @@
+<?php
+
+// helpers for the metrics tests
+
+function simple($a) {
+    return $a;
+}
+
+function branches($a, $b = 1, ...$rest) {
+    if ($a && $b && $c) {
+        foreach ($rest as $r) {
+            if ($r || $a) {
+                return 1;
+            } elseif ($r) {
+                return 2;
+            } else {
+                return 3;
+            }
+        }
+    }
+    $x = $a ? 1 : 2;
+    $y = $a ?? $b;
+    for ($i = 0; $i < 10; $i++) {
+        while (true) {
+            continue 2;
+        }
+    }
+
+    return $x;
+}
+
+function nullsafe($a) {
+    return $a?->b?->c();
+}
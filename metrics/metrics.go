@@ -0,0 +1,369 @@
+// Package metrics computes size and complexity metrics for PHP source code.
+//
+// There is no parser yet, so functions and classes are the declarations the
+// decl package finds in the token stream: the keyword up to the brace that
+// closes the body. Nested functions and closures are measured on their own
+// and do not count towards the function they are declared in.
+package metrics
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/bestform/shmehashme/decl"
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Lines counts the lines of a file or function
+type Lines struct {
+	Physical int `json:"physical"` // all lines, including blank ones
+	Logical  int `json:"logical"`  // statements terminated by a semicolon
+	Comment  int `json:"comment"`  // lines containing (part of) a comment
+}
+
+// Function holds the metrics of one function, method or closure
+type Function struct {
+	Name       string `json:"name"`            // "{closure}" for anonymous functions
+	Class      string `json:"class,omitempty"` // the class of a method
+	Line       int    `json:"line"`
+	Cyclomatic int    `json:"cyclomatic"`
+	Cognitive  int    `json:"cognitive"`
+	Nesting    int    `json:"nesting"` // deepest level of nested braces in the body
+	Params     int    `json:"params"`
+	Lines      Lines  `json:"lines"`
+}
+
+// Class holds the metrics of one class, interface or trait
+type Class struct {
+	Name    string `json:"name"` // "class@anonymous" for anonymous classes
+	Line    int    `json:"line"`
+	Methods int    `json:"methods"`
+}
+
+// File holds the metrics of one source file
+type File struct {
+	Path      string     `json:"path"`
+	Lines     Lines      `json:"lines"`
+	Classes   []Class    `json:"classes"`
+	Functions []Function `json:"functions"`
+}
+
+// Analyze reads the source code from r and computes its metrics
+func Analyze(path string, r io.Reader) (File, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return File{}, err
+	}
+
+	l, err := lexer.New(strings.NewReader(string(src)))
+	if err != nil {
+		return File{}, err
+	}
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	return AnalyzeTokens(path, string(src), tokens), nil
+}
+
+// AnalyzeTokens computes the metrics of source code that has already been
+// lexed. tokens are the tokens of src without the EOF token.
+func AnalyzeTokens(path, src string, tokens []lexer.Token) File {
+	a := newAnalysis(tokens)
+	f := File{
+		Path:      path,
+		Classes:   []Class{},
+		Functions: []Function{},
+	}
+	f.Lines = countLines(tokens)
+	f.Lines.Physical = strings.Count(src, "\n")
+	if len(src) > 0 && src[len(src)-1] != '\n' {
+		f.Lines.Physical++
+	}
+
+	found := decl.Find(src, tokens)
+	for i, d := range found {
+		if d.Kind == decl.Function || d.Kind == decl.Method || d.Body < 0 {
+			continue
+		}
+		c := Class{Name: className(d), Line: tokens[d.Keyword].Line}
+		for _, m := range found {
+			if m.Parent == i {
+				c.Methods++
+			}
+		}
+		f.Classes = append(f.Classes, c)
+	}
+	for _, d := range found {
+		if d.Kind != decl.Function && d.Kind != decl.Method {
+			continue
+		}
+		// abstract and interface methods have no body to measure
+		if d.Params < 0 || d.Body < 0 || d.End < 0 {
+			continue
+		}
+		if _, ok := a.matching[d.Params]; !ok {
+			continue
+		}
+
+		fn := functionRange{Function{Name: "{closure}", Line: tokens[d.Keyword].Line}, d.Keyword, d.Params, d.Body, d.End}
+		if !d.Anonymous() {
+			fn.Name = d.Name
+		}
+		if d.Parent >= 0 {
+			fn.Class = className(found[d.Parent])
+		}
+		f.Functions = append(f.Functions, a.measure(fn))
+	}
+
+	return f
+}
+
+// analysis holds the token stream of a file together with the brace structure
+type analysis struct {
+	tokens   []lexer.Token
+	matching map[int]int // index of an opening brace or parenthesis -> index of the closing one
+}
+
+func newAnalysis(tokens []lexer.Token) *analysis {
+	a := &analysis{
+		tokens:   tokens,
+		matching: map[int]int{},
+	}
+
+	var braces, parens []int
+	for i, tok := range tokens {
+		switch tok.Type {
+		case lexer.LBRACE:
+			braces = append(braces, i)
+		case lexer.RBRACE:
+			if len(braces) > 0 {
+				a.matching[braces[len(braces)-1]] = i
+				braces = braces[:len(braces)-1]
+			}
+		case lexer.LPAREN:
+			parens = append(parens, i)
+		case lexer.RPAREN:
+			if len(parens) > 0 {
+				a.matching[parens[len(parens)-1]] = i
+				parens = parens[:len(parens)-1]
+			}
+		}
+	}
+
+	return a
+}
+
+// is reports whether the token at i is an identifier spelled like one of
+// the given (lower case) keywords the lexer does not know about yet
+func (a *analysis) is(i int, keywords ...string) bool {
+	if i < 0 || i >= len(a.tokens) || a.tokens[i].Type != lexer.IDENT {
+		return false
+	}
+	lit := strings.ToLower(a.tokens[i].Literal)
+	for _, k := range keywords {
+		if lit == k {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *analysis) typeAt(i int) lexer.TokenType {
+	if i < 0 || i >= len(a.tokens) {
+		return lexer.EOF
+	}
+	return a.tokens[i].Type
+}
+
+// next returns the index of the first token of type t at or after i,
+// stopping at a semicolon. It returns -1 if there is none.
+func (a *analysis) next(i int, t lexer.TokenType) int {
+	for ; i < len(a.tokens); i++ {
+		switch a.tokens[i].Type {
+		case t:
+			return i
+		case lexer.SEMICOLON:
+			return -1
+		}
+	}
+
+	return -1
+}
+
+type functionRange struct {
+	Function
+	start  int // index of the FUNCTION token
+	params int // index of the opening parenthesis of the parameter list
+	body   int // index of the opening brace of the body
+	end    int // index of the closing brace of the body
+}
+
+// className returns the name of a class, interface or trait
+func className(d decl.Declaration) string {
+	if d.Anonymous() {
+		return "class@anonymous"
+	}
+	return d.Name
+}
+
+func (a *analysis) measure(fn functionRange) Function {
+	f := fn.Function
+
+	for i := fn.params + 1; i < a.matching[fn.params]; i++ {
+		if a.tokens[i].Type == lexer.VAR && a.parenDepth(fn.params, i) == 1 {
+			f.Params++
+		}
+	}
+
+	f.Lines = countLines(a.tokens[fn.start : fn.end+1])
+	f.Lines.Physical = a.tokens[fn.end].Line - f.Line + 1
+
+	f.Cyclomatic = 1
+	var lastOperator lexer.TokenType
+	depth := 0
+	for i := fn.body + 1; i < fn.end; i++ {
+		tok := a.tokens[i]
+
+		switch {
+		case tok.Type == lexer.FUNCTION:
+			// nested functions are measured on their own
+			if body := a.next(i, lexer.LBRACE); body >= 0 {
+				if end, ok := a.matching[body]; ok && end < fn.end {
+					i = end
+				}
+			}
+			continue
+		case tok.Type == lexer.LBRACE:
+			depth++
+			if depth > f.Nesting {
+				f.Nesting = depth
+			}
+		case tok.Type == lexer.RBRACE:
+			depth--
+		}
+
+		switch {
+		case tok.Type == lexer.IF && a.is(i-1, "else"):
+			// "else if" was already counted as else
+			f.Cyclomatic++
+		case tok.Type == lexer.IF, tok.Type == lexer.FOR, tok.Type == lexer.FOREACH,
+			a.is(i, "switch", "catch"):
+			f.Cyclomatic++
+			f.Cognitive += 1 + depth
+		case a.is(i, "while"):
+			f.Cyclomatic++
+			if !a.isDoWhile(i) {
+				f.Cognitive += 1 + depth
+			}
+		case a.is(i, "do"):
+			f.Cognitive += 1 + depth
+		case tok.Type == lexer.QUESTIONMARK:
+			if a.typeAt(i+1) == lexer.QUESTIONMARK {
+				// null coalescing operator "??"
+				i++
+				f.Cyclomatic++
+				continue
+			}
+			if a.typeAt(i+1) == lexer.ARROW {
+				// nullsafe operator "?->"
+				continue
+			}
+			f.Cyclomatic++
+			f.Cognitive += 1 + depth
+		case a.is(i, "case"):
+			f.Cyclomatic++
+		case a.is(i, "elseif", "else"):
+			f.Cognitive++
+			if a.is(i, "elseif") {
+				f.Cyclomatic++
+			}
+		case a.is(i, "goto"):
+			f.Cognitive++
+		case a.is(i, "break", "continue") && a.typeAt(i+1) == lexer.INT:
+			f.Cognitive++
+		case tok.Type == lexer.IDENT && tok.Literal == f.Name && fn.Class == "" &&
+			a.typeAt(i+1) == lexer.LPAREN && a.typeAt(i-1) != lexer.ARROW:
+			// recursion
+			f.Cognitive++
+		}
+
+		// every sequence of like logical operators adds to the cognitive complexity
+		switch {
+		case tok.Type == lexer.AND || tok.Type == lexer.OR || a.is(i, "and", "or"):
+			f.Cyclomatic++
+			op := tok.Type
+			if op == lexer.IDENT {
+				op = lexer.TokenType(strings.ToUpper(tok.Literal))
+			}
+			if op != lastOperator {
+				f.Cognitive++
+			}
+			lastOperator = op
+		case tok.Type == lexer.SEMICOLON, tok.Type == lexer.LBRACE, tok.Type == lexer.RBRACE:
+			lastOperator = ""
+		}
+	}
+
+	return f
+}
+
+// parenDepth returns how many parentheses are open at i, counting from open
+func (a *analysis) parenDepth(open, i int) int {
+	depth := 0
+	for j := open; j < i; j++ {
+		switch a.tokens[j].Type {
+		case lexer.LPAREN:
+			depth++
+		case lexer.RPAREN:
+			depth--
+		}
+	}
+
+	return depth
+}
+
+// isDoWhile reports whether the while at i closes a do-while loop
+func (a *analysis) isDoWhile(i int) bool {
+	if a.typeAt(i-1) != lexer.RBRACE {
+		return false
+	}
+	for open, end := range a.matching {
+		if end == i-1 && a.tokens[open].Type == lexer.LBRACE {
+			return a.is(open-1, "do")
+		}
+	}
+
+	return false
+}
+
+// countLines counts the statements and comment lines in tokens. The number
+// of physical lines is left to the caller.
+func countLines(tokens []lexer.Token) Lines {
+	var lines Lines
+	commentLines := map[int]bool{}
+	parens := 0
+	for _, tok := range tokens {
+		switch tok.Type {
+		case lexer.LPAREN:
+			parens++
+		case lexer.RPAREN:
+			parens--
+		case lexer.SEMICOLON:
+			// the semicolons in the head of a for loop do not end statements
+			if parens <= 0 {
+				lines.Logical++
+			}
+		case lexer.COMMENT:
+			for l := 0; l <= strings.Count(tok.Literal, "\n"); l++ {
+				commentLines[tok.Line+l] = true
+			}
+		}
+	}
+	lines.Comment = len(commentLines)
+
+	return lines
+}
@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var tests = []File{
+	{
+		Path:    "fixtures/complexity.php",
+		Lines:   Lines{Physical: 34, Logical: 9, Comment: 1},
+		Classes: []Class{},
+		Functions: []Function{
+			{Name: "simple", Line: 5, Cyclomatic: 1, Cognitive: 0, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 3, Logical: 1}},
+			{Name: "branches", Line: 9, Cyclomatic: 12, Cognitive: 15, Nesting: 3, Params: 3,
+				Lines: Lines{Physical: 22, Logical: 7}},
+			{Name: "nullsafe", Line: 32, Cyclomatic: 1, Cognitive: 0, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 3, Logical: 1}},
+		},
+	},
+	{
+		Path:  "fixtures/classes.php",
+		Lines: Lines{Physical: 34, Logical: 10, Comment: 3},
+		Classes: []Class{
+			{Name: "Greeter", Line: 3, Methods: 1},
+			{Name: "Foo", Line: 7, Methods: 2},
+			{Name: "class@anonymous", Line: 24, Methods: 1},
+		},
+		Functions: []Function{
+			{Name: "greet", Class: "Foo", Line: 11, Cyclomatic: 1, Cognitive: 0, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 6, Logical: 3}},
+			{Name: "{closure}", Line: 12, Cyclomatic: 1, Cognitive: 0, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 3, Logical: 1}},
+			{Name: "factorial", Class: "Foo", Line: 18, Cyclomatic: 2, Cognitive: 1, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 3, Logical: 1}},
+			{Name: "run", Class: "class@anonymous", Line: 25, Cyclomatic: 2, Cognitive: 1, Nesting: 1, Params: 0,
+				Lines: Lines{Physical: 5, Logical: 2}},
+			{Name: "fib", Line: 32, Cyclomatic: 2, Cognitive: 3, Nesting: 0, Params: 1,
+				Lines: Lines{Physical: 3, Logical: 1}},
+		},
+	},
+}
+
+func analyzeFixture(t *testing.T, filename string) File {
+	input, err := os.Open(filename)
+	if err != nil {
+		t.Fatal("error opening test fixture", err)
+	}
+	defer input.Close()
+
+	f, err := Analyze(filename, input)
+	if err != nil {
+		t.Fatal("error analyzing fixture", err)
+	}
+
+	return f
+}
+
+func TestAnalyze(t *testing.T) {
+
+	for _, expected := range tests {
+		f := analyzeFixture(t, expected.Path)
+
+		if f.Lines != expected.Lines {
+			t.Fatalf("%v - lines wrong. expected=%+v, got=%+v", expected.Path, expected.Lines, f.Lines)
+		}
+		if !reflect.DeepEqual(f.Classes, expected.Classes) {
+			t.Fatalf("%v - classes wrong. expected=%+v, got=%+v", expected.Path, expected.Classes, f.Classes)
+		}
+		if len(f.Functions) != len(expected.Functions) {
+			t.Fatalf("%v - expected %d functions, got %+v", expected.Path, len(expected.Functions), f.Functions)
+		}
+		for i, fn := range f.Functions {
+			if fn != expected.Functions[i] {
+				t.Fatalf("%v - functions[%d] wrong.\nexpected=%+v\ngot=     %+v", expected.Path, i, expected.Functions[i], fn)
+			}
+		}
+	}
+
+}
+
+func TestCheck(t *testing.T) {
+
+	thresholds := Thresholds{Cyclomatic: 10, Cognitive: 2, Nesting: 2, Params: 2, Lines: 20, Methods: 1}
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"fixtures/classes.php", []string{
+			"fixtures/classes.php:7: class Foo has 2 methods (max 1)",
+			"fixtures/classes.php:32: function fib has a cognitive complexity of 3 (max 2)",
+		}},
+		{"fixtures/complexity.php", []string{
+			"fixtures/complexity.php:9: function branches has a cyclomatic complexity of 12 (max 10)",
+			"fixtures/complexity.php:9: function branches has a cognitive complexity of 15 (max 2)",
+			"fixtures/complexity.php:9: function branches is nested 3 levels deep (max 2)",
+			"fixtures/complexity.php:9: function branches has 3 parameters (max 2)",
+			"fixtures/complexity.php:9: function branches has 22 lines (max 20)",
+		}},
+	}
+
+	for _, test := range tests {
+		f := analyzeFixture(t, test.path)
+
+		var actual []string
+		for _, d := range f.Check(thresholds) {
+			actual = append(actual, d.String())
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Fatalf("%v - diagnostics wrong.\nexpected=%s\ngot=     %s", test.path, strings.Join(test.expected, "\n"), strings.Join(actual, "\n"))
+		}
+
+		if diagnostics := f.Check(Thresholds{}); len(diagnostics) != 0 {
+			t.Fatalf("%v - expected no diagnostics without thresholds, got %v", test.path, diagnostics)
+		}
+	}
+
+}
+
+func TestWriteCSV(t *testing.T) {
+
+	f := analyzeFixture(t, "fixtures/complexity.php")
+
+	var b bytes.Buffer
+	if err := WriteCSV(&b, []File{f}); err != nil {
+		t.Fatal("error writing CSV", err)
+	}
+
+	expected := "kind,path,line,name,cyclomatic,cognitive,nesting,params,physical_lines,logical_lines,comment_lines,methods\n" +
+		"file,fixtures/complexity.php,,,,,,,34,9,1,\n" +
+		"function,fixtures/complexity.php,5,simple,1,0,0,1,3,1,0,\n" +
+		"function,fixtures/complexity.php,9,branches,12,15,3,3,22,7,0,\n" +
+		"function,fixtures/complexity.php,32,nullsafe,1,0,0,1,3,1,0,\n"
+	if b.String() != expected {
+		t.Fatalf("Output does not match expected output:\nEXPECTED:\n%s\n\nACTUAL:\n%s", expected, b.String())
+	}
+
+}
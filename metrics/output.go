@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// WriteText writes the metrics as a human readable table
+func WriteText(w io.Writer, files []File) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "LOCATION\tNAME\tCYCLOMATIC\tCOGNITIVE\tNESTING\tPARAMS\tLINES\tLOGICAL\tCOMMENT\tMETHODS")
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t\t\t\t\t\t%d\t%d\t%d\n", f.Path, f.Lines.Physical, f.Lines.Logical, f.Lines.Comment)
+		for _, c := range f.Classes {
+			fmt.Fprintf(tw, "%s:%d\t%s\t\t\t\t\t\t\t\t%d\n", f.Path, c.Line, c.Name, c.Methods)
+		}
+		for _, fn := range f.Functions {
+			fmt.Fprintf(tw, "%s:%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", f.Path, fn.Line, qualifiedName(fn),
+				fn.Cyclomatic, fn.Cognitive, fn.Nesting, fn.Params, fn.Lines.Physical, fn.Lines.Logical, fn.Lines.Comment)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// WriteCSV writes the metrics as CSV with one row per file, class and function
+func WriteCSV(w io.Writer, files []File) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"kind", "path", "line", "name", "cyclomatic", "cognitive", "nesting", "params",
+		"physical_lines", "logical_lines", "comment_lines", "methods"})
+
+	itoa := strconv.Itoa
+	for _, f := range files {
+		cw.Write([]string{"file", f.Path, "", "", "", "", "", "",
+			itoa(f.Lines.Physical), itoa(f.Lines.Logical), itoa(f.Lines.Comment), ""})
+		for _, c := range f.Classes {
+			cw.Write([]string{"class", f.Path, itoa(c.Line), c.Name, "", "", "", "", "", "", "", itoa(c.Methods)})
+		}
+		for _, fn := range f.Functions {
+			cw.Write([]string{"function", f.Path, itoa(fn.Line), qualifiedName(fn),
+				itoa(fn.Cyclomatic), itoa(fn.Cognitive), itoa(fn.Nesting), itoa(fn.Params),
+				itoa(fn.Lines.Physical), itoa(fn.Lines.Logical), itoa(fn.Lines.Comment), ""})
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// WriteJSON writes the metrics as a JSON array with one object per file
+func WriteJSON(w io.Writer, files []File) error {
+	if files == nil {
+		files = []File{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(files)
+}
+
+func qualifiedName(fn Function) string {
+	if fn.Class == "" {
+		return fn.Name
+	}
+	return fn.Class + "::" + fn.Name
+}
@@ -0,0 +1,514 @@
+package phpdoc
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tEOF      tokenKind = iota
+	tName               // identifiers with backslashes and dashes like \Foo\Bar or class-string
+	tVariable           // $name
+	tNumber
+	tString
+	tPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	start int  // offset of the token after the skipped whitespace
+	end   int  // offset after the token
+	space bool // whether whitespace precedes the token
+}
+
+var puncts = []string{"...", "::", "<", ">", ",", "|", "&", "?", "(", ")", "[", "]", "{", "}", ":", "=", "*"}
+
+// lex returns the token at pos. Types are lexed lazily, as the text
+// following a type in a tag is a description that does not need to be valid.
+func lex(s string, pos int) token {
+	start := pos
+	for pos < len(s) && isSpace(s[pos]) {
+		pos++
+	}
+	tok := token{start: pos, end: pos, space: pos > start}
+	if pos >= len(s) {
+		return tok
+	}
+
+	end := pos + 1
+	c := s[pos]
+	switch {
+	case isNameStart(c):
+		tok.kind = tName
+		end = scanName(s, pos)
+	case c == '$' && pos+1 < len(s) && isNameStart(s[pos+1]):
+		tok.kind = tVariable
+		end = scanName(s, pos+1)
+	case isDigit(c) || c == '-' && pos+1 < len(s) && isDigit(s[pos+1]):
+		tok.kind = tNumber
+		for end < len(s) && (isNameChar(s[end]) || s[end] == '.') {
+			end++
+		}
+	case c == '\'' || c == '"':
+		for end < len(s) && s[end] != c {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			// an unterminated string is left to the parser as a single quote
+			tok.kind = tPunct
+			end = pos + 1
+			break
+		}
+		tok.kind = tString
+		end++
+	default:
+		tok.kind = tPunct
+		for _, p := range puncts {
+			if strings.HasPrefix(s[pos:], p) {
+				end = pos + len(p)
+				break
+			}
+		}
+	}
+	tok.text = s[pos:end]
+	tok.end = end
+
+	return tok
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNameStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '\\' || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+// scanName returns the end of the name starting at pos. Dashes are part of
+// a name if a letter or digit follows them.
+func scanName(s string, pos int) int {
+	for pos < len(s) {
+		switch {
+		case isNameChar(s[pos]):
+			pos++
+		case s[pos] == '-' && pos+1 < len(s) && isNameChar(s[pos+1]):
+			pos++
+		default:
+			return pos
+		}
+	}
+
+	return pos
+}
+
+// shapeKinds and callableKinds are the names that can be followed by a
+// shape or a signature
+var shapeKinds = map[string]bool{
+	"array": true, "list": true, "object": true, "non-empty-array": true, "non-empty-list": true,
+}
+
+var callableKinds = map[string]bool{
+	"callable": true, "Closure": true, `\Closure`: true, "pure-callable": true, "pure-Closure": true,
+}
+
+type parser struct {
+	s     string
+	pos   int   // offset after the last consumed token
+	tok   token // the next token
+	depth int   // nesting of brackets, whitespace only ends a type outside of them
+}
+
+func newParser(s string) *parser {
+	return &parser{s: s, tok: lex(s, 0)}
+}
+
+// ParseType parses a phpDoc type like array<int, Foo>|null
+func ParseType(s string) (Type, error) {
+	p := newParser(s)
+	t, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tEOF {
+		return nil, p.unexpected("end of type")
+	}
+
+	return t, nil
+}
+
+func (p *parser) next() {
+	p.pos = p.tok.end
+	p.tok = lex(p.s, p.pos)
+}
+
+func (p *parser) peek() token {
+	return lex(p.s, p.tok.end)
+}
+
+func (p *parser) is(text string) bool {
+	return p.tok.kind == tPunct && p.tok.text == text
+}
+
+// attached reports whether the next token is text and belongs to the type
+// before it. Outside of brackets "int (the count)" is a type followed by a
+// description, so no whitespace may come before the token.
+func (p *parser) attached(text string) bool {
+	return p.is(text) && (!p.tok.space || p.depth > 0)
+}
+
+func (p *parser) expect(text string) error {
+	if !p.is(text) {
+		return p.unexpected(fmt.Sprintf("%q", text))
+	}
+	p.next()
+
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	found := "end of type"
+	if p.tok.kind != tEOF {
+		found = fmt.Sprintf("%q", p.tok.text)
+	}
+
+	return fmt.Errorf("unexpected %s at offset %d, expected %s", found, p.tok.start, expected)
+}
+
+func (p *parser) parseType() (Type, error) {
+	if p.tok.kind == tName || p.tok.kind == tVariable {
+		if next := p.peek(); next.kind == tName && next.text == "is" {
+			// without parentheses "bool is returned" may be a type and a description
+			saved := *p
+			t, err := p.parseConditional()
+			if err == nil || p.depth > 0 {
+				return t, err
+			}
+			*p = saved
+		}
+	}
+
+	return p.parseUnion()
+}
+
+func (p *parser) parseConditional() (Type, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+
+	t := &Conditional{Subject: p.tok.text}
+	p.next() // subject
+	p.next() // is
+	if p.tok.kind == tName && p.tok.text == "not" {
+		t.Negated = true
+		p.next()
+	}
+
+	var err error
+	if t.Target, err = p.parseUnion(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("?"); err != nil {
+		return nil, err
+	}
+	if t.Then, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if t.Else, err = p.parseType(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (p *parser) parseUnion() (Type, error) {
+	t, err := p.parseIntersection()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("|") {
+		return t, nil
+	}
+
+	u := &Union{Types: []Type{t}}
+	for p.is("|") {
+		p.next()
+		t, err := p.parseIntersection()
+		if err != nil {
+			return nil, err
+		}
+		u.Types = append(u.Types, t)
+	}
+
+	return u, nil
+}
+
+func (p *parser) parseIntersection() (Type, error) {
+	t, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isIntersection() {
+		return t, nil
+	}
+
+	in := &Intersection{Types: []Type{t}}
+	for p.isIntersection() {
+		p.next()
+		t, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		in.Types = append(in.Types, t)
+	}
+
+	return in, nil
+}
+
+// isIntersection reports whether the next token is an "&" joining two
+// types. In "int &$x" it marks a parameter passed by reference instead.
+func (p *parser) isIntersection() bool {
+	if !p.is("&") {
+		return false
+	}
+	next := p.peek()
+
+	return next.kind != tVariable && !(next.kind == tPunct && next.text == "...")
+}
+
+func (p *parser) parsePostfix() (Type, error) {
+	t, err := p.parseAtom()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.attached("[") {
+		p.depth++
+		p.next()
+		if p.is("]") {
+			t = &Array{t}
+		} else {
+			index, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			t = &Offset{t, index}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		p.depth--
+	}
+
+	return t, nil
+}
+
+func (p *parser) parseAtom() (Type, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tNumber, tString:
+		p.next()
+		return &Literal{tok.text}, nil
+	case tVariable:
+		p.next()
+		return &Name{tok.text}, nil
+	case tName:
+		p.next()
+		switch {
+		case p.attached("::"):
+			return p.parseConstant(tok.text)
+		case p.attached("<"):
+			return p.parseGeneric(tok.text)
+		case p.attached("{") && shapeKinds[tok.text]:
+			return p.parseShape(tok.text)
+		case p.attached("(") && callableKinds[tok.text]:
+			return p.parseCallable(tok.text)
+		}
+		return &Name{tok.text}, nil
+	case tPunct:
+		switch tok.text {
+		case "?":
+			p.next()
+			t, err := p.parsePostfix()
+			if err != nil {
+				return nil, err
+			}
+			return &Nullable{t}, nil
+		case "(":
+			p.depth++
+			p.next()
+			t, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			p.depth--
+			return t, nil
+		}
+	}
+
+	return nil, p.unexpected("type")
+}
+
+// parseConstant parses the part of Foo::BAR or Foo::BAR_* after the class
+func (p *parser) parseConstant(class string) (Type, error) {
+	p.next() // ::
+	if p.tok.kind != tName && !p.is("*") {
+		return nil, p.unexpected("constant name")
+	}
+	value := class + "::" + p.tok.text
+	p.next()
+	for !p.tok.space && (p.tok.kind == tName || p.is("*")) {
+		value += p.tok.text
+		p.next()
+	}
+
+	return &Literal{value}, nil
+}
+
+func (p *parser) parseGeneric(base string) (Type, error) {
+	p.depth++
+	p.next() // <
+	t := &Generic{Base: Name{base}}
+	for !p.is(">") {
+		arg, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		t.Args = append(t.Args, arg)
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(">"); err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	return t, nil
+}
+
+func (p *parser) parseShape(kind string) (Type, error) {
+	p.depth++
+	p.next() // {
+	t := &Shape{Kind: kind, Sealed: true}
+	for !p.is("}") {
+		if p.is("...") {
+			t.Sealed = false
+			p.next()
+			if p.is(",") {
+				p.next()
+			}
+			break
+		}
+
+		var item ShapeItem
+		if p.isShapeKey() {
+			item.Key = p.tok.text
+			p.next()
+			if p.is("?") {
+				item.Optional = true
+				p.next()
+			}
+			p.next() // :
+		}
+		var err error
+		if item.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		t.Items = append(t.Items, item)
+
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	return t, nil
+}
+
+// isShapeKey reports whether the next token is the key of a shape item,
+// which is followed by ":" or "?:"
+func (p *parser) isShapeKey() bool {
+	if p.tok.kind != tName && p.tok.kind != tString && p.tok.kind != tNumber {
+		return false
+	}
+	next := p.peek()
+	if next.kind == tPunct && next.text == "?" {
+		next = lex(p.s, next.end)
+	}
+
+	return next.kind == tPunct && next.text == ":"
+}
+
+func (p *parser) parseCallable(kind string) (Type, error) {
+	p.depth++
+	p.next() // (
+	t := &Callable{Kind: kind}
+	for !p.is(")") {
+		var param Param
+		var err error
+		if param.Type, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		if p.is("&") {
+			param.ByRef = true
+			p.next()
+		}
+		if p.is("...") {
+			param.Variadic = true
+			p.next()
+		}
+		if p.tok.kind == tVariable {
+			param.Name = p.tok.text
+			p.next()
+		}
+		if p.is("=") {
+			param.Optional = true
+			p.next()
+		}
+		t.Params = append(t.Params, param)
+
+		if !p.is(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	// like in a PHP signature the return type cannot be a union without parentheses
+	if p.attached(":") {
+		p.next()
+		var err error
+		if t.Return, err = p.parsePostfix(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
@@ -0,0 +1,238 @@
+// Package phpdoc parses doc comments: the summary, the description and the
+// tags with their phpDoc types, including the extensions of Psalm and PHPStan
+// like generics, shapes, callable signatures and conditional types.
+//
+// There is no parser for PHP yet, so Find attaches each doc comment to the
+// token following it instead of a node of a syntax tree.
+package phpdoc
+
+import (
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Doc is a parsed doc comment
+type Doc struct {
+	Summary     string
+	Description string
+	Tags        []Tag
+}
+
+// Tag is a tag like "@param int $id the ID" of a doc comment. The fields
+// that are filled depend on the tag.
+type Tag struct {
+	Name        string // without "@", e.g. "param" or "psalm-return"
+	Type        Type   // nil for tags without a type or if the type is invalid
+	Variable    string // the parameter or property with "$", or the name of a template parameter
+	ByRef       bool   // @param int &$x
+	Variadic    bool   // @param int ...$x
+	Description string
+	Err         error // set if the type of the tag could not be parsed
+}
+
+// Base returns the name of the tag without a "psalm-" or "phpstan-" prefix
+func (t Tag) Base() string {
+	for _, prefix := range []string{"psalm-", "phpstan-"} {
+		if strings.HasPrefix(t.Name, prefix) {
+			return t.Name[len(prefix):]
+		}
+	}
+
+	return t.Name
+}
+
+// TagsNamed returns the tags with the name
+func (d *Doc) TagsNamed(name string) []Tag {
+	var tags []Tag
+	for _, tag := range d.Tags {
+		if tag.Name == name {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// tag kinds by their base name
+const (
+	untyped       = iota
+	typed         // a type followed by a description
+	typedVariable // a type, a variable and a description
+	templateTag   // a name with an optional bound
+)
+
+var tagKinds = map[string]int{
+	"param":                  typedVariable,
+	"param-out":              typedVariable,
+	"var":                    typedVariable,
+	"property":               typedVariable,
+	"property-read":          typedVariable,
+	"property-write":         typedVariable,
+	"assert":                 typedVariable,
+	"assert-if-true":         typedVariable,
+	"assert-if-false":        typedVariable,
+	"return":                 typed,
+	"throws":                 typed,
+	"mixin":                  typed,
+	"extends":                typed,
+	"implements":             typed,
+	"use":                    typed,
+	"template-extends":       typed,
+	"template-implements":    typed,
+	"template-use":           typed,
+	"self-out":               typed,
+	"template":               templateTag,
+	"template-covariant":     templateTag,
+	"template-contravariant": templateTag,
+}
+
+// Parse parses a doc comment with or without its "/**" and "*/" markers.
+// Invalid types do not stop the parsing, they are reported in the Err field
+// of their tag.
+func Parse(comment string) *Doc {
+	comment = strings.TrimSuffix(strings.TrimPrefix(comment, "/**"), "*/")
+
+	var text []string
+	var tags [][]string // the lines of each tag
+	for i, line := range strings.Split(comment, "\n") {
+		line = strings.TrimLeft(strings.TrimRight(line, " \t\r"), " \t")
+		if i > 0 && strings.HasPrefix(line, "*") {
+			line = strings.TrimPrefix(line[1:], " ")
+		}
+
+		switch {
+		case isTagStart(line):
+			tags = append(tags, []string{line})
+		case len(tags) > 0:
+			tags[len(tags)-1] = append(tags[len(tags)-1], strings.TrimSpace(line))
+		default:
+			text = append(text, line)
+		}
+	}
+
+	d := &Doc{}
+	d.Summary, d.Description = splitSummary(text)
+	for _, lines := range tags {
+		d.Tags = append(d.Tags, parseTag(strings.TrimSpace(strings.Join(lines, "\n"))))
+	}
+
+	return d
+}
+
+func isTagStart(line string) bool {
+	return len(line) > 1 && line[0] == '@' && isNameStart(line[1])
+}
+
+// splitSummary splits the text in front of the tags. The summary ends with
+// the first empty line or the first line ending with a period.
+func splitSummary(lines []string) (string, string) {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+
+	end := 0
+	for end < len(lines) && lines[end] != "" {
+		end++
+		if strings.HasSuffix(lines[end-1], ".") {
+			break
+		}
+	}
+
+	summary := strings.Join(lines[:end], "\n")
+	description := strings.TrimSpace(strings.Join(lines[end:], "\n"))
+
+	return summary, description
+}
+
+// parseTag parses a tag starting with "@"
+func parseTag(text string) Tag {
+	end := 1
+	for end < len(text) && (isNameChar(text[end]) || text[end] == '-') {
+		end++
+	}
+	tag := Tag{Name: text[1:end]}
+	rest := strings.TrimSpace(text[end:])
+
+	kind := tagKinds[tag.Base()]
+	if kind == untyped {
+		tag.Description = rest
+		return tag
+	}
+
+	if kind == templateTag {
+		name := lex(rest, 0)
+		if name.kind != tName {
+			tag.Description = rest
+			return tag
+		}
+		tag.Variable = name.text
+		rest = strings.TrimSpace(rest[name.end:])
+		bound := lex(rest, 0)
+		if bound.kind != tName || bound.text != "of" && bound.text != "as" {
+			tag.Description = rest
+			return tag
+		}
+		rest = rest[bound.end:]
+	}
+
+	p := newParser(rest)
+	t, err := p.parseType()
+	if err != nil {
+		tag.Err = err
+		tag.Description = rest
+		return tag
+	}
+	tag.Type = t
+	rest = strings.TrimSpace(rest[p.pos:])
+
+	if kind == typedVariable {
+		if strings.HasPrefix(rest, "&") {
+			tag.ByRef = true
+			rest = rest[1:]
+		}
+		if strings.HasPrefix(rest, "...") {
+			tag.Variadic = true
+			rest = rest[3:]
+		}
+		if v := lex(rest, 0); v.kind == tVariable && !v.space {
+			tag.Variable = v.text
+			rest = rest[v.end:]
+		}
+		rest = strings.TrimSpace(rest)
+	}
+	tag.Description = rest
+
+	return tag
+}
+
+// Docblock is a doc comment in a token stream
+type Docblock struct {
+	Comment int // index of the comment token
+	Target  int // index of the next token that is not a comment, -1 if there is none
+	Doc     *Doc
+}
+
+// Find parses the doc comments among the tokens of src. A doc comment
+// belongs to the token following it, usually the first modifier or keyword
+// of a declaration or the variable of an assignment.
+func Find(src string, tokens []lexer.Token) []Docblock {
+	var blocks []Docblock
+	for i, tok := range tokens {
+		text := src[tok.Offset:tok.End]
+		if tok.Type != lexer.COMMENT || !strings.HasPrefix(text, "/**") || text == "/**/" {
+			continue
+		}
+
+		target := -1
+		for j := i + 1; j < len(tokens); j++ {
+			if tokens[j].Type != lexer.COMMENT {
+				target = j
+				break
+			}
+		}
+		blocks = append(blocks, Docblock{i, target, Parse(text)})
+	}
+
+	return blocks
+}
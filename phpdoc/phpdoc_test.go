@@ -0,0 +1,243 @@
+package phpdoc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+func TestParseType(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int", "int"},
+		{`\Foo\Bar`, `\Foo\Bar`},
+		{"?Foo", "?Foo"},
+		{"int|string|null", "int|string|null"},
+		{"int | string", "int|string"},
+		{"Foo&Bar", "Foo&Bar"},
+		{"(Foo&Bar)|null", "(Foo&Bar)|null"},
+		{"Foo[]", "Foo[]"},
+		{"(int|string)[][]", "(int|string)[][]"},
+		{"?int[]", "?int[]"},
+		{"T[K]", "T[K]"},
+		{"array<int, Foo>", "array<int, Foo>"},
+		{"array<array-key,list<non-empty-string>>", "array<array-key, list<non-empty-string>>"},
+		{"class-string<T>", "class-string<T>"},
+		{"key-of<self::MAP>", "key-of<self::MAP>"},
+		{"array{id: int, name?: string}", "array{id: int, name?: string}"},
+		{"array{int, string}", "array{int, string}"},
+		{"array{'a-b': int, 0: bool, ...}", "array{'a-b': int, 0: bool, ...}"},
+		{"list{int, array{x: float}}", "list{int, array{x: float}}"},
+		{"object{foo: int}", "object{foo: int}"},
+		{"array{\n  id: int,\n  name: string,\n}", "array{id: int, name: string}"},
+		{"callable", "callable"},
+		{"callable(int, string=): void", "callable(int, string=): void"},
+		{"Closure(Foo &$a, int ...$rest): ?Bar", "Closure(Foo &$a, int ...$rest): ?Bar"},
+		{`\Closure(): (int|false)`, `\Closure(): (int|false)`},
+		{"(callable(): int)|null", "(callable(): int)|null"},
+		{"($x is int ? string : bool)", "($x is int ? string : bool)"},
+		{"T is not null ? T : never", "(T is not null ? T : never)"},
+		{"($x is true ? (T is int ? int : float) : null)", "($x is true ? (T is int ? int : float) : null)"},
+		{"'foo'|\"bar\"|1|-2.5", "'foo'|\"bar\"|1|-2.5"},
+		{"Foo::BAR|Foo::BAZ_*|Foo::*", "Foo::BAR|Foo::BAZ_*|Foo::*"},
+		{"$this", "$this"},
+		{"ä", "ä"},
+	}
+
+	for _, test := range tests {
+		typ, err := ParseType(test.input)
+		if err != nil {
+			t.Errorf("%q - unexpected error: %v", test.input, err)
+			continue
+		}
+		if typ.String() != test.expected {
+			t.Errorf("%q - expected %q, got %q", test.input, test.expected, typ.String())
+			continue
+		}
+
+		// the normalized notation parses to the same type
+		again, err := ParseType(typ.String())
+		if err != nil || again.String() != typ.String() {
+			t.Errorf("%q - normalized type %q does not parse to itself: %v", test.input, typ.String(), err)
+		}
+	}
+
+}
+
+func TestParseTypeErrors(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "unexpected end of type at offset 0, expected type"},
+		{"array<int", "unexpected end of type at offset 9, expected \">\""},
+		{"array{a: }", "unexpected \"}\" at offset 9, expected type"},
+		{"int|", "unexpected end of type at offset 4, expected type"},
+		{"int string", "unexpected \"string\" at offset 4, expected end of type"},
+		{"'open", "unexpected \"'\" at offset 0, expected type"},
+		{"($x is int ? string)", "unexpected \")\" at offset 19, expected \":\""},
+	}
+
+	for _, test := range tests {
+		_, err := ParseType(test.input)
+		if err == nil {
+			t.Errorf("%q - expected error", test.input)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("%q - expected error %q, got %q", test.input, test.expected, err)
+		}
+	}
+
+}
+
+func TestParse(t *testing.T) {
+
+	doc := Parse(`/**
+     * Finds the users of a group.
+     * Inactive users are left out
+     * unless asked for.
+     *
+     * @param array<int, Group>|Group $group the group,
+     *        or several
+     * @param int &$count set to the number of users
+     * @param string ...$roles
+     * @psalm-param non-empty-string ...$roles
+     * @return ($group is Group ? list<User> : array<int, list<User>>)
+     * @throws \RuntimeException if the database is gone
+     * @deprecated 2.0 use findMembers
+     * @template T of User
+     * @template-covariant K
+     * @phpstan-var array{id: int} $row
+     * @see findMembers()
+     * @return array<int the type is broken
+     */`)
+
+	if doc.Summary != "Finds the users of a group." {
+		t.Errorf("unexpected summary %q", doc.Summary)
+	}
+	if doc.Description != "Inactive users are left out\nunless asked for." {
+		t.Errorf("unexpected description %q", doc.Description)
+	}
+
+	tests := []struct {
+		name        string
+		typ         string
+		variable    string
+		byRef       bool
+		variadic    bool
+		description string
+		err         string
+	}{
+		{"param", "array<int, Group>|Group", "$group", false, false, "the group,\nor several", ""},
+		{"param", "int", "$count", true, false, "set to the number of users", ""},
+		{"param", "string", "$roles", false, true, "", ""},
+		{"psalm-param", "non-empty-string", "$roles", false, true, "", ""},
+		{"return", "($group is Group ? list<User> : array<int, list<User>>)", "", false, false, "", ""},
+		{"throws", `\RuntimeException`, "", false, false, "if the database is gone", ""},
+		{"deprecated", "", "", false, false, "2.0 use findMembers", ""},
+		{"template", "User", "T", false, false, "", ""},
+		{"template-covariant", "", "K", false, false, "", ""},
+		{"phpstan-var", "array{id: int}", "$row", false, false, "", ""},
+		{"see", "", "", false, false, "findMembers()", ""},
+		{"return", "", "", false, false, "array<int the type is broken", "unexpected \"the\" at offset 10, expected \">\""},
+	}
+
+	if len(doc.Tags) != len(tests) {
+		t.Fatalf("expected %d tags, got %d: %+v", len(tests), len(doc.Tags), doc.Tags)
+	}
+	for i, test := range tests {
+		tag := doc.Tags[i]
+		typ := ""
+		if tag.Type != nil {
+			typ = tag.Type.String()
+		}
+		err := ""
+		if tag.Err != nil {
+			err = tag.Err.Error()
+		}
+		if tag.Name != test.name || typ != test.typ || tag.Variable != test.variable || tag.ByRef != test.byRef ||
+			tag.Variadic != test.variadic || tag.Description != test.description || err != test.err {
+			t.Errorf("tag %d - expected %+v, got %s %q %q %v %v %q %q", i, test, tag.Name, typ, tag.Variable, tag.ByRef, tag.Variadic, tag.Description, err)
+		}
+	}
+
+	if len(doc.TagsNamed("return")) != 2 {
+		t.Errorf("expected 2 return tags, got %d", len(doc.TagsNamed("return")))
+	}
+	if doc.Tags[3].Base() != "param" {
+		t.Errorf("expected base name param, got %q", doc.Tags[3].Base())
+	}
+
+}
+
+func TestParseSummary(t *testing.T) {
+
+	tests := []struct {
+		input       string
+		summary     string
+		description string
+	}{
+		{"/** @var int $x */", "", ""},
+		{"/** Short. */", "Short.", ""},
+		{"/**\n * Two lines\n * of summary\n *\n * Text\n *\n * More\n */", "Two lines\nof summary", "Text\n\nMore"},
+		{"/**\n * No period\n */", "No period", ""},
+		{"/**\n *\n * First. Second\n * @return int\n */", "First. Second", ""},
+	}
+
+	for _, test := range tests {
+		doc := Parse(test.input)
+		if doc.Summary != test.summary || doc.Description != test.description {
+			t.Errorf("%q - expected %q and %q, got %q and %q", test.input, test.summary, test.description, doc.Summary, doc.Description)
+		}
+	}
+
+}
+
+func TestFind(t *testing.T) {
+
+	src := "<?php\n/** @var int $a */\n$a = 1;\n/**/\n/** Foo. */ // note\nfinal class Foo {}\n/** @return void */"
+	l, err := lexer.New(strings.NewReader(src))
+	if err != nil {
+		t.Fatal("error creating lexer", err)
+	}
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	blocks := Find(src, tokens)
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 doc comments, got %d", len(blocks))
+	}
+
+	expected := []struct {
+		target  string
+		summary string
+		tag     string
+	}{
+		{"$a", "", "var"},
+		{"final", "Foo.", ""},
+		{"", "", "return"},
+	}
+	for i, e := range expected {
+		b := blocks[i]
+		target := ""
+		if b.Target >= 0 {
+			target = tokens[b.Target].Literal
+		}
+		tag := ""
+		if len(b.Doc.Tags) > 0 {
+			tag = b.Doc.Tags[0].Name
+		}
+		if target != e.target || b.Doc.Summary != e.summary || tag != e.tag {
+			t.Errorf("doc comment %d - expected %+v, got %q %q %q", i, e, target, b.Doc.Summary, tag)
+		}
+	}
+
+}
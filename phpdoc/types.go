@@ -0,0 +1,206 @@
+package phpdoc
+
+import "strings"
+
+// Type is a node of a parsed phpDoc type. String returns the type in a
+// normalized notation that parses to the same type again.
+type Type interface {
+	String() string
+}
+
+// Name is a type referred to by its name: a built-in type like int or
+// class-string, a class, a template parameter or $this
+type Name struct {
+	Name string
+}
+
+// Literal is a constant used as a type: a number, a quoted string or a class
+// constant like Foo::BAR, which may contain wildcards as in Foo::BAR_*
+type Literal struct {
+	Value string
+}
+
+// Nullable is ?Type
+type Nullable struct {
+	Type Type
+}
+
+// Array is Elem[]
+type Array struct {
+	Elem Type
+}
+
+// Offset is the type of an offset of another type, Type[Index]
+type Offset struct {
+	Type  Type
+	Index Type
+}
+
+// Generic is a type with type arguments like array<int, Foo>
+type Generic struct {
+	Base Name
+	Args []Type
+}
+
+// Union is A|B
+type Union struct {
+	Types []Type
+}
+
+// Intersection is A&B
+type Intersection struct {
+	Types []Type
+}
+
+// Shape is an array or object with known keys like array{id: int, name?: string}
+type Shape struct {
+	Kind   string // array, list, object, non-empty-array or non-empty-list
+	Items  []ShapeItem
+	Sealed bool // false if the shape ends with "..." and may have more items
+}
+
+// ShapeItem is an item of a shape. Items of list-like shapes have no key.
+type ShapeItem struct {
+	Key      string // as written, quoted keys keep their quotes
+	Optional bool
+	Type     Type
+}
+
+// Callable is a callable with a signature like callable(int, string=): bool
+type Callable struct {
+	Kind   string // callable, Closure, \Closure, pure-callable or pure-Closure
+	Params []Param
+	Return Type // nil if there is no return type
+}
+
+// Param is a parameter of a callable
+type Param struct {
+	Type     Type
+	ByRef    bool
+	Variadic bool
+	Name     string // with "$", empty if the parameter is not named
+	Optional bool   // marked with "="
+}
+
+// Conditional is (Subject is Target ? Then : Else)
+type Conditional struct {
+	Subject string // a parameter with "$" or a template parameter
+	Negated bool   // "is not"
+	Target  Type
+	Then    Type
+	Else    Type
+}
+
+func (t *Name) String() string    { return t.Name }
+func (t *Literal) String() string { return t.Value }
+func (t *Nullable) String() string {
+	return "?" + atom(t.Type)
+}
+func (t *Array) String() string {
+	return atom(t.Elem) + "[]"
+}
+func (t *Offset) String() string {
+	return atom(t.Type) + "[" + t.Index.String() + "]"
+}
+func (t *Generic) String() string {
+	return t.Base.Name + "<" + join(t.Args, ", ") + ">"
+}
+func (t *Union) String() string {
+	return joinMembers(t.Types, "|")
+}
+func (t *Intersection) String() string {
+	return joinMembers(t.Types, "&")
+}
+
+func (t *Shape) String() string {
+	var items []string
+	for _, item := range t.Items {
+		s := item.Type.String()
+		if item.Key != "" {
+			key := item.Key
+			if item.Optional {
+				key += "?"
+			}
+			s = key + ": " + s
+		}
+		items = append(items, s)
+	}
+	if !t.Sealed {
+		items = append(items, "...")
+	}
+
+	return t.Kind + "{" + strings.Join(items, ", ") + "}"
+}
+
+func (t *Callable) String() string {
+	var params []string
+	for _, p := range t.Params {
+		s := p.Type.String()
+		if p.ByRef || p.Variadic || p.Name != "" {
+			s += " "
+		}
+		if p.ByRef {
+			s += "&"
+		}
+		if p.Variadic {
+			s += "..."
+		}
+		s += p.Name
+		if p.Optional {
+			s += "="
+		}
+		params = append(params, s)
+	}
+
+	s := t.Kind + "(" + strings.Join(params, ", ") + ")"
+	if t.Return != nil {
+		s += ": " + atom(t.Return)
+	}
+
+	return s
+}
+
+func (t *Conditional) String() string {
+	is := " is "
+	if t.Negated {
+		is = " is not "
+	}
+
+	return "(" + t.Subject + is + t.Target.String() + " ? " + t.Then.String() + " : " + t.Else.String() + ")"
+}
+
+// atom returns the type in parentheses if a suffix or prefix would otherwise
+// bind to only a part of it
+func atom(t Type) string {
+	switch t := t.(type) {
+	case *Union, *Intersection:
+		return "(" + t.String() + ")"
+	case *Callable:
+		if t.Return != nil {
+			return "(" + t.String() + ")"
+		}
+	}
+
+	return t.String()
+}
+
+// joinMembers joins the members of a union or intersection. Unions in
+// intersections and intersections in unions keep their parentheses, as do
+// callables with a return type.
+func joinMembers(types []Type, sep string) string {
+	var parts []string
+	for _, t := range types {
+		parts = append(parts, atom(t))
+	}
+
+	return strings.Join(parts, sep)
+}
+
+func join(types []Type, sep string) string {
+	var parts []string
+	for _, t := range types {
+		parts = append(parts, t.String())
+	}
+
+	return strings.Join(parts, sep)
+}
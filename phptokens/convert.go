@@ -0,0 +1,400 @@
+package phptokens
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// names maps the token types of the lexer to token names where they correspond directly.
+// Everything else is derived from the source, because the lexer splits or merges
+// some tokens differently than PHP does.
+var names = map[lexer.TokenType]string{
+	lexer.VAR:                "T_VARIABLE",
+	lexer.INT:                "T_LNUMBER",
+	lexer.FLOAT:              "T_DNUMBER",
+	lexer.SINGLEQUOTEDSTRING: "T_CONSTANT_ENCAPSED_STRING",
+	lexer.DOUBLEQUOTEDSTRING: "T_CONSTANT_ENCAPSED_STRING",
+	lexer.COMMENT:            "T_COMMENT",
+	lexer.PHPTAG:             "T_OPEN_TAG",
+
+	lexer.EQUALS:             "T_IS_EQUAL",
+	lexer.IDENTITY:           "T_IS_IDENTICAL",
+	lexer.LESSTHANOREQUAL:    "T_IS_SMALLER_OR_EQUAL",
+	lexer.GREATERTHANOREQUAL: "T_IS_GREATER_OR_EQUAL",
+	lexer.OR:                 "T_BOOLEAN_OR",
+	lexer.AND:                "T_BOOLEAN_AND",
+	lexer.INC:                "T_INC",
+	lexer.DEC:                "T_DEC",
+	lexer.DOUBLEARROW:        "T_DOUBLE_ARROW",
+	lexer.ARROW:              "T_OBJECT_OPERATOR",
+	lexer.SPACESHIP:          "T_SPACESHIP",
+
+	lexer.FUNCTION:   "T_FUNCTION",
+	lexer.RETURN:     "T_RETURN",
+	lexer.PUBLIC:     "T_PUBLIC",
+	lexer.PRIVATE:    "T_PRIVATE",
+	lexer.STATIC:     "T_STATIC",
+	lexer.PROTECTED:  "T_PROTECTED",
+	lexer.CLASS:      "T_CLASS",
+	lexer.IMPLEMENTS: "T_IMPLEMENTS",
+	lexer.EXTENDS:    "T_EXTENDS",
+	lexer.IF:         "T_IF",
+	lexer.TRUE:       "T_STRING",
+	lexer.FALSE:      "T_STRING",
+	lexer.USE:        "T_USE",
+	lexer.FOR:        "T_FOR",
+	lexer.FOREACH:    "T_FOREACH",
+	lexer.AS:         "T_AS",
+}
+
+// Name returns the token name PHP uses for a token type of the lexer, or an
+// empty string if PHP returns it as a plain character or has no single
+// equivalent (like for IDENT, which may be a keyword or a name)
+func Name(t lexer.TokenType) string {
+	return names[t]
+}
+
+// keywords are matched case insensitively and only outside of names
+var keywords = map[string]string{
+	"abstract": "T_ABSTRACT", "and": "T_LOGICAL_AND", "array": "T_ARRAY", "as": "T_AS",
+	"break": "T_BREAK", "callable": "T_CALLABLE", "case": "T_CASE", "catch": "T_CATCH",
+	"class": "T_CLASS", "clone": "T_CLONE", "const": "T_CONST", "continue": "T_CONTINUE",
+	"declare": "T_DECLARE", "default": "T_DEFAULT", "die": "T_EXIT", "do": "T_DO",
+	"echo": "T_ECHO", "else": "T_ELSE", "elseif": "T_ELSEIF", "empty": "T_EMPTY",
+	"enddeclare": "T_ENDDECLARE", "endfor": "T_ENDFOR", "endforeach": "T_ENDFOREACH",
+	"endif": "T_ENDIF", "endswitch": "T_ENDSWITCH", "endwhile": "T_ENDWHILE", "eval": "T_EVAL",
+	"exit": "T_EXIT", "extends": "T_EXTENDS", "final": "T_FINAL", "finally": "T_FINALLY",
+	"fn": "T_FN", "for": "T_FOR", "foreach": "T_FOREACH", "function": "T_FUNCTION",
+	"global": "T_GLOBAL", "goto": "T_GOTO", "if": "T_IF", "implements": "T_IMPLEMENTS",
+	"include": "T_INCLUDE", "include_once": "T_INCLUDE_ONCE", "instanceof": "T_INSTANCEOF",
+	"insteadof": "T_INSTEADOF", "interface": "T_INTERFACE", "isset": "T_ISSET", "list": "T_LIST",
+	"match": "T_MATCH", "namespace": "T_NAMESPACE", "new": "T_NEW", "or": "T_LOGICAL_OR",
+	"print": "T_PRINT", "private": "T_PRIVATE", "protected": "T_PROTECTED", "public": "T_PUBLIC",
+	"require": "T_REQUIRE", "require_once": "T_REQUIRE_ONCE", "return": "T_RETURN",
+	"static": "T_STATIC", "switch": "T_SWITCH", "throw": "T_THROW", "trait": "T_TRAIT",
+	"try": "T_TRY", "unset": "T_UNSET", "use": "T_USE", "var": "T_VAR", "while": "T_WHILE",
+	"xor": "T_LOGICAL_XOR", "yield": "T_YIELD",
+
+	"__class__": "T_CLASS_C", "__dir__": "T_DIR", "__file__": "T_FILE",
+	"__function__": "T_FUNC_C", "__line__": "T_LINE", "__method__": "T_METHOD_C",
+	"__namespace__": "T_NS_C", "__trait__": "T_TRAIT_C",
+}
+
+// operators are matched longest first
+var operators = []struct{ text, name string }{
+	{"<<=", "T_SL_EQUAL"}, {">>=", "T_SR_EQUAL"}, {"**=", "T_POW_EQUAL"}, {"??=", "T_COALESCE_EQUAL"},
+	{"===", "T_IS_IDENTICAL"}, {"!==", "T_IS_NOT_IDENTICAL"}, {"<=>", "T_SPACESHIP"},
+	{"?->", "T_NULLSAFE_OBJECT_OPERATOR"}, {"...", "T_ELLIPSIS"},
+
+	{"==", "T_IS_EQUAL"}, {"!=", "T_IS_NOT_EQUAL"}, {"<>", "T_IS_NOT_EQUAL"},
+	{"<=", "T_IS_SMALLER_OR_EQUAL"}, {">=", "T_IS_GREATER_OR_EQUAL"},
+	{"+=", "T_PLUS_EQUAL"}, {"-=", "T_MINUS_EQUAL"}, {"*=", "T_MUL_EQUAL"}, {"/=", "T_DIV_EQUAL"},
+	{".=", "T_CONCAT_EQUAL"}, {"%=", "T_MOD_EQUAL"}, {"&=", "T_AND_EQUAL"}, {"|=", "T_OR_EQUAL"},
+	{"^=", "T_XOR_EQUAL"}, {"<<", "T_SL"}, {">>", "T_SR"}, {"**", "T_POW"}, {"??", "T_COALESCE"},
+	{"++", "T_INC"}, {"--", "T_DEC"}, {"->", "T_OBJECT_OPERATOR"}, {"=>", "T_DOUBLE_ARROW"},
+	{"::", "T_DOUBLE_COLON"}, {"&&", "T_BOOLEAN_AND"}, {"||", "T_BOOLEAN_OR"},
+}
+
+var casts = map[string]string{
+	"int": "T_INT_CAST", "integer": "T_INT_CAST",
+	"bool": "T_BOOL_CAST", "boolean": "T_BOOL_CAST",
+	"float": "T_DOUBLE_CAST", "double": "T_DOUBLE_CAST", "real": "T_DOUBLE_CAST",
+	"string": "T_STRING_CAST", "binary": "T_STRING_CAST",
+	"array": "T_ARRAY_CAST", "object": "T_OBJECT_CAST", "unset": "T_UNSET_CAST",
+}
+
+const label = `[a-zA-Z_\x{80}-\x{10ffff}][a-zA-Z0-9_\x{80}-\x{10ffff}]*`
+
+var (
+	variablePattern  = regexp.MustCompile(`^\$` + label)
+	namePattern      = regexp.MustCompile(`^\\?` + label + `(\\` + label + `)*\\?`)
+	labelPattern     = regexp.MustCompile(`^` + label)
+	yieldFromPattern = regexp.MustCompile(`^(?i)yield[ \t\r\n]+from\b`)
+	castPattern      = regexp.MustCompile(`^\([ \t]*([a-zA-Z]+)[ \t]*\)`)
+	numberPattern    = regexp.MustCompile(`^(0[xX][0-9a-fA-F]+(_[0-9a-fA-F]+)*|0[bB][01]+(_[01]+)*|` +
+		`((\d+(_\d+)*)?\.\d+(_\d+)*|\d+(_\d+)*\.?)([eE][+-]?\d+(_\d+)*)?)`)
+)
+
+// convert converts the source starting with tok and reports whether it was a close tag
+func (c *converter) convert(tok lexer.Token) bool {
+	rest := c.src[c.pos:]
+
+	switch {
+	case tok.Type == lexer.COMMENT:
+		return c.comment(tok)
+	case tok.Type == lexer.SINGLEQUOTEDSTRING:
+		c.emit(names[tok.Type], tok.End)
+	case tok.Type == lexer.DOUBLEQUOTEDSTRING:
+		c.doubleQuoted(tok.End)
+	case strings.HasPrefix(rest, "?>"):
+		c.closeTag()
+		return true
+	case strings.HasPrefix(rest, "#["):
+		c.emit("T_ATTRIBUTE", c.pos+2)
+	case strings.HasPrefix(rest, "#"):
+		return c.lineComment()
+	case variablePattern.MatchString(rest):
+		c.emit("T_VARIABLE", c.pos+len(variablePattern.FindString(rest)))
+	case numberPattern.MatchString(rest):
+		c.number(numberPattern.FindString(rest))
+	case namePattern.MatchString(rest):
+		c.name(namePattern.FindString(rest))
+	case castPattern.MatchString(rest) && casts[strings.ToLower(castPattern.FindStringSubmatch(rest)[1])] != "":
+		m := castPattern.FindStringSubmatch(rest)
+		c.emit(casts[strings.ToLower(m[1])], c.pos+len(m[0]))
+	default:
+		c.operator()
+	}
+
+	return false
+}
+
+func (c *converter) closeTag() {
+	end := c.pos + len("?>")
+	if strings.HasPrefix(c.src[end:], "\r\n") {
+		end += 2
+	} else if strings.HasPrefix(c.src[end:], "\n") {
+		end++
+	}
+	c.emit("T_CLOSE_TAG", end)
+}
+
+// comment converts a comment of the lexer. Single line comments end before a close tag.
+func (c *converter) comment(tok lexer.Token) bool {
+	text := c.src[tok.Offset:tok.End]
+	if strings.HasPrefix(text, "//") {
+		if i := strings.Index(text, "?>"); i >= 0 {
+			c.emit("T_COMMENT", tok.Offset+i)
+			c.closeTag()
+			return true
+		}
+	}
+
+	name := "T_COMMENT"
+	if strings.HasPrefix(text, "/**") && len(text) > len("/**") && strings.ContainsRune(" \t\r\n", rune(text[3])) {
+		name = "T_DOC_COMMENT"
+	}
+	c.emit(name, tok.End)
+
+	return false
+}
+
+// lineComment converts a comment starting with "#", which the lexer does not know
+func (c *converter) lineComment() bool {
+	rest := c.src[c.pos:]
+	end := len(rest)
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		end = i
+	}
+	if i := strings.Index(rest[:end], "?>"); i >= 0 {
+		c.emit("T_COMMENT", c.pos+i)
+		c.closeTag()
+		return true
+	}
+	c.emit("T_COMMENT", c.pos+end)
+
+	return false
+}
+
+// number converts an integer or a float. Integers that do not fit into 64 bits are floats in PHP.
+func (c *converter) number(text string) {
+	name := "T_LNUMBER"
+	isHex := strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X")
+	if strings.ContainsAny(text, ".") || !isHex && strings.ContainsAny(text, "eE") {
+		name = "T_DNUMBER"
+	} else if _, err := strconv.ParseInt(strings.Replace(text, "_", "", -1), 0, 64); err != nil {
+		name = "T_DNUMBER"
+	}
+	c.emit(name, c.pos+len(text))
+}
+
+// name converts keywords, identifiers and namespaced names
+func (c *converter) name(text string) {
+	if strings.HasSuffix(text, `\`) {
+		// as in "use Foo\{Bar, Baz}"
+		c.name(text[:len(text)-1])
+		c.emit("T_NS_SEPARATOR", c.pos+1)
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	end := c.pos + len(text)
+	lower := strings.ToLower(text)
+	switch {
+	case strings.HasPrefix(text, `\`):
+		c.emit("T_NAME_FULLY_QUALIFIED", end)
+	case strings.HasPrefix(lower, `namespace\`):
+		c.emit("T_NAME_RELATIVE", end)
+	case strings.Contains(text, `\`):
+		c.emit("T_NAME_QUALIFIED", end)
+	case c.afterObjectOperator():
+		// property and method names may be keywords
+		c.emit("T_STRING", end)
+	case lower == "yield" && yieldFromPattern.MatchString(c.src[c.pos:]):
+		c.emit("T_YIELD_FROM", c.pos+len(yieldFromPattern.FindString(c.src[c.pos:])))
+	case keywords[lower] != "":
+		c.emit(keywords[lower], end)
+	default:
+		c.emit("T_STRING", end)
+	}
+}
+
+// afterObjectOperator reports whether the last token that is not whitespace is -> or ?->
+func (c *converter) afterObjectOperator() bool {
+	for i := len(c.tokens) - 1; i >= 0; i-- {
+		switch c.tokens[i].Name {
+		case "T_WHITESPACE":
+			continue
+		case "T_OBJECT_OPERATOR", "T_NULLSAFE_OBJECT_OPERATOR":
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// operator converts operators and everything else PHP returns as plain characters
+func (c *converter) operator() {
+	rest := c.src[c.pos:]
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op.text) {
+			c.emit(op.name, c.pos+len(op.text))
+			return
+		}
+	}
+
+	_, size := utf8.DecodeRuneInString(rest)
+	c.tokens = append(c.tokens, Token{"", rest[:size], c.lineAt(c.pos)})
+	c.pos += size
+}
+
+// doubleQuoted converts a double quoted string ending at end. Strings with
+// variables in them are split into their parts.
+func (c *converter) doubleQuoted(end int) {
+	body := c.src[c.pos+1 : end]
+	if !hasInterpolation(body) {
+		c.emit("T_CONSTANT_ENCAPSED_STRING", end)
+		return
+	}
+	closing := end
+	if strings.HasSuffix(body, `"`) {
+		closing--
+	}
+
+	c.operator() // the opening quote
+
+	for i := c.pos; i < closing; {
+		rest := c.src[i:closing]
+		switch {
+		case rest[0] == '\\':
+			i += 2
+			continue
+		case variablePattern.MatchString(rest):
+			c.emit("T_ENCAPSED_AND_WHITESPACE", i)
+			c.emit("T_VARIABLE", i+len(variablePattern.FindString(rest)))
+			c.variableSuffix(closing)
+		case strings.HasPrefix(rest, "{$"):
+			c.emit("T_ENCAPSED_AND_WHITESPACE", i)
+			c.emit("T_CURLY_OPEN", i+1)
+			c.embedded(closing)
+		case strings.HasPrefix(rest, "${"):
+			c.emit("T_ENCAPSED_AND_WHITESPACE", i)
+			c.emit("T_DOLLAR_OPEN_CURLY_BRACES", i+2)
+			if m := labelPattern.FindString(c.src[c.pos:closing]); m != "" {
+				if next := c.src[c.pos+len(m) : closing]; strings.HasPrefix(next, "}") || strings.HasPrefix(next, "[") {
+					c.emit("T_STRING_VARNAME", c.pos+len(m))
+				}
+			}
+			c.embedded(closing)
+		default:
+			i++
+			continue
+		}
+		i = c.pos
+	}
+	c.emit("T_ENCAPSED_AND_WHITESPACE", closing)
+	if closing < end {
+		c.operator() // the closing quote
+	}
+}
+
+// hasInterpolation reports whether the body of a double quoted string contains variables
+func hasInterpolation(body string) bool {
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\':
+			i++
+		case body[i] == '$' && (variablePattern.MatchString(body[i:]) || strings.HasPrefix(body[i:], "${")):
+			return true
+		case strings.HasPrefix(body[i:], "{$"):
+			return true
+		}
+	}
+	return false
+}
+
+// variableSuffix converts an array offset or a property access directly
+// following a variable in a double quoted string
+func (c *converter) variableSuffix(closing int) {
+	rest := c.src[c.pos:closing]
+	switch {
+	case strings.HasPrefix(rest, "->") && labelPattern.MatchString(rest[2:]):
+		c.emit("T_OBJECT_OPERATOR", c.pos+2)
+		c.emit("T_STRING", c.pos+len(labelPattern.FindString(rest[2:])))
+	case strings.HasPrefix(rest, "?->") && labelPattern.MatchString(rest[3:]):
+		c.emit("T_NULLSAFE_OBJECT_OPERATOR", c.pos+3)
+		c.emit("T_STRING", c.pos+len(labelPattern.FindString(rest[3:])))
+	case strings.HasPrefix(rest, "["):
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return
+		}
+		offset := rest[1:end]
+		var name string
+		switch {
+		case variablePattern.MatchString(offset) && variablePattern.FindString(offset) == offset:
+			name = "T_VARIABLE"
+		case labelPattern.FindString(offset) == offset && offset != "":
+			name = "T_STRING"
+		case offset != "" && strings.Trim(offset, "0123456789") == "":
+			name = "T_NUM_STRING"
+		default:
+			return
+		}
+		c.operator() // [
+		c.emit(name, c.pos+len(offset))
+		c.operator() // ]
+	}
+}
+
+// embedded converts the code of "{$...}" and "${...}" in a double quoted
+// string up to the matching closing brace
+func (c *converter) embedded(closing int) {
+	depth := 1
+	end := c.pos
+	for ; end < closing; end++ {
+		if c.src[end] == '{' {
+			depth++
+		}
+		if c.src[end] == '}' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+
+	inner := &converter{src: c.src[:end], pos: c.pos, newlines: c.newlines}
+	inner.php()
+	c.tokens = append(c.tokens, inner.tokens...)
+	c.pos = end
+	if end < closing {
+		c.operator() // }
+	}
+}
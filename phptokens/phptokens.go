@@ -0,0 +1,177 @@
+// Package phptokens converts source code into the token list PHP's
+// token_get_all function returns, so tools written against that format can
+// consume the output of this lexer.
+//
+// The output follows PHP 8.0: names such as T_NAME_QUALIFIED exist, single
+// line comments do not contain the trailing newline and "&" is returned as a
+// plain character. Heredocs, nowdocs, backtick strings, short open tags and
+// __halt_compiler are not supported yet.
+package phptokens
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Version is the PHP version whose token_get_all output is reproduced
+const Version = "8.0"
+
+// Token is one element of the token_get_all result. Characters PHP returns as
+// plain strings (like ";" or "{") have an empty Name.
+type Token struct {
+	Name string
+	Text string
+	Line int
+}
+
+// IDs maps token names to the numeric values of the T_* constants of a PHP
+// version. Generate them with token_ids.php.
+type IDs map[string]int
+
+// LoadIDs reads a JSON object of token names and IDs as printed by token_ids.php
+func LoadIDs(r io.Reader) (IDs, error) {
+	var ids IDs
+	if err := json.NewDecoder(r).Decode(&ids); err != nil {
+		return nil, fmt.Errorf("error reading token IDs: %v", err)
+	}
+	return ids, nil
+}
+
+// PrintJSON prints the tokens as the JSON encoding of the array token_get_all
+// returns: plain characters as strings and all other tokens as [id, text, line].
+// If ids is nil, the token names are used instead of the numeric IDs.
+func PrintJSON(tokens []Token, ids IDs) (string, error) {
+	elements := make([]interface{}, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Name == "" {
+			elements = append(elements, tok.Text)
+			continue
+		}
+		if ids == nil {
+			elements = append(elements, []interface{}{tok.Name, tok.Text, tok.Line})
+			continue
+		}
+		id, ok := ids[tok.Name]
+		if !ok {
+			return "", fmt.Errorf("no ID for token %s", tok.Name)
+		}
+		elements = append(elements, []interface{}{id, tok.Text, tok.Line})
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(elements); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// Convert lexes src and returns the tokens token_get_all would return for it
+func Convert(src string) []Token {
+	return convert(src).tokens
+}
+
+func convert(src string) *converter {
+	c := &converter{src: src}
+	for i, ch := range src {
+		if ch == '\n' {
+			c.newlines = append(c.newlines, i)
+		}
+	}
+
+	for c.pos < len(src) {
+		c.html()
+		c.php()
+	}
+
+	return c
+}
+
+type converter struct {
+	src      string
+	pos      int   // everything before pos has been converted
+	newlines []int // offsets of all newlines in src
+	tokens   []Token
+	lexed    int // number of tokens read from the lexer
+}
+
+// lineAt returns the line of the byte at offset
+func (c *converter) lineAt(offset int) int {
+	return sort.SearchInts(c.newlines, offset) + 1
+}
+
+func (c *converter) emit(name string, end int) {
+	if end <= c.pos {
+		return
+	}
+	c.tokens = append(c.tokens, Token{name, c.src[c.pos:end], c.lineAt(c.pos)})
+	c.pos = end
+}
+
+// html converts everything up to and including the next open tag
+func (c *converter) html() {
+	start := c.pos
+	for {
+		i := strings.Index(c.src[start:], "<?")
+		if i < 0 {
+			start = len(c.src)
+			break
+		}
+		start += i
+		if strings.HasPrefix(c.src[start:], "<?php") || strings.HasPrefix(c.src[start:], "<?=") {
+			break
+		}
+		start += len("<?")
+	}
+	c.emit("T_INLINE_HTML", start)
+	rest := c.src[c.pos:]
+
+	switch {
+	case strings.HasPrefix(rest, "<?="):
+		c.emit("T_OPEN_TAG_WITH_ECHO", c.pos+3)
+	case strings.HasPrefix(rest, "<?php"):
+		end := c.pos + len("<?php")
+		switch {
+		case strings.HasPrefix(c.src[end:], "\r\n"):
+			end += 2
+		case end < len(c.src) && strings.ContainsRune(" \t\n\r", rune(c.src[end])):
+			end++
+		}
+		c.emit("T_OPEN_TAG", end)
+	}
+}
+
+// php converts code up to and including the next close tag. The lexer stops
+// there, so every block is lexed only once.
+func (c *converter) php() {
+	if c.pos >= len(c.src) {
+		return
+	}
+
+	l := lexer.NewAt(c.src, c.pos, c.lineAt(c.pos))
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		c.lexed++
+		if tok.Offset < c.pos {
+			if tok.End > c.pos {
+				// the converted text ended inside of this token, so the rest has to be lexed again
+				l = lexer.NewAt(c.src, c.pos, c.lineAt(c.pos))
+			}
+			continue
+		}
+
+		c.emit("T_WHITESPACE", tok.Offset)
+		if closed := c.convert(tok); closed {
+			return
+		}
+	}
+	c.emit("T_WHITESPACE", len(c.src))
+}
@@ -0,0 +1,138 @@
+package phptokens
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The expected files in testdata contain what PHP 8.0 returns for the fixtures,
+// with token names in place of the numeric IDs. They were written by hand and
+// still have to be replaced with the output of record.php run by PHP 8.0.
+func TestConvertFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/*.php")
+	if err != nil {
+		t.Fatal("error listing fixtures", err)
+	}
+
+	for _, fixture := range fixtures {
+		src, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal("error reading fixture", err)
+		}
+		expected, err := ioutil.ReadFile(strings.TrimSuffix(fixture, ".php") + ".json")
+		if err != nil {
+			t.Fatal("error reading expected tokens", err)
+		}
+
+		tokens := Convert(string(src))
+		o, err := PrintJSON(tokens, nil)
+		if err != nil {
+			t.Fatal("error printing tokens", err)
+		}
+		var got, want interface{}
+		if err := json.Unmarshal([]byte(o), &got); err != nil {
+			t.Fatal("error decoding tokens", err)
+		}
+		if err := json.Unmarshal(expected, &want); err != nil {
+			t.Fatal("error decoding expected tokens", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v - unexpected tokens:\n%s", fixture, o)
+		}
+
+		var text strings.Builder
+		for _, tok := range tokens {
+			text.WriteString(tok.Text)
+		}
+		if text.String() != string(src) {
+			t.Errorf("%v - tokens do not add up to the source:\n%s", fixture, text.String())
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected []Token
+	}{
+		{"", nil},
+		{"<p>no php</p>", []Token{{"T_INLINE_HTML", "<p>no php</p>", 1}}},
+		{"<?php\r\n$a;", []Token{{"T_OPEN_TAG", "<?php\r\n", 1}, {"T_VARIABLE", "$a", 2}, {"", ";", 2}}},
+		{"<?= $a ?>", []Token{
+			{"T_OPEN_TAG_WITH_ECHO", "<?=", 1}, {"T_WHITESPACE", " ", 1},
+			{"T_VARIABLE", "$a", 1}, {"T_WHITESPACE", " ", 1}, {"T_CLOSE_TAG", "?>", 1},
+		}},
+		{"<?php $a->class; A::class;", []Token{
+			{"T_OPEN_TAG", "<?php ", 1}, {"T_VARIABLE", "$a", 1}, {"T_OBJECT_OPERATOR", "->", 1},
+			{"T_STRING", "class", 1}, {"", ";", 1}, {"T_WHITESPACE", " ", 1},
+			{"T_STRING", "A", 1}, {"T_DOUBLE_COLON", "::", 1}, {"T_CLASS", "class", 1}, {"", ";", 1},
+		}},
+		{"<?php # it's\n'a';", []Token{
+			{"T_OPEN_TAG", "<?php ", 1}, {"T_COMMENT", "# it's", 1}, {"T_WHITESPACE", "\n", 1},
+			{"T_CONSTANT_ENCAPSED_STRING", "'a'", 2}, {"", ";", 2},
+		}},
+		{"<?php /**/ /** a */", []Token{
+			{"T_OPEN_TAG", "<?php ", 1}, {"T_COMMENT", "/**/", 1}, {"T_WHITESPACE", " ", 1},
+			{"T_DOC_COMMENT", "/** a */", 1},
+		}},
+		{"<?php \"a\nb$c\";", []Token{
+			{"T_OPEN_TAG", "<?php ", 1}, {"", "\"", 1}, {"T_ENCAPSED_AND_WHITESPACE", "a\nb", 1},
+			{"T_VARIABLE", "$c", 2}, {"", "\"", 2}, {"", ";", 2},
+		}},
+		{"<?php $é = \"$o->p[1]\";", []Token{
+			{"T_OPEN_TAG", "<?php ", 1}, {"T_VARIABLE", "$é", 1}, {"T_WHITESPACE", " ", 1},
+			{"", "=", 1}, {"T_WHITESPACE", " ", 1}, {"", "\"", 1}, {"T_VARIABLE", "$o", 1},
+			{"T_OBJECT_OPERATOR", "->", 1}, {"T_STRING", "p", 1}, {"T_ENCAPSED_AND_WHITESPACE", "[1]", 1},
+			{"", "\"", 1}, {"", ";", 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		tokens := Convert(tt.src)
+		if !reflect.DeepEqual(tokens, tt.expected) {
+			t.Errorf("%q - expected %v, got %v", tt.src, tt.expected, tokens)
+		}
+	}
+}
+
+func TestConvertLexesOnce(t *testing.T) {
+	// many small blocks of PHP, each ending in a comment the lexer does not know
+	for _, blocks := range []int{1, 10, 1000} {
+		src := strings.Repeat("<p>html</p>\n<?php $a = [1, 'b']; # note\n?>\n", blocks)
+		c := convert(src)
+
+		// lexing every block up to its close tag once reads fewer tokens than
+		// the conversion returns, lexing up to the end of the input each time
+		// would read a number growing with the square of the blocks
+		if c.lexed > len(c.tokens) {
+			t.Errorf("%d blocks - read %d tokens from the lexer for %d tokens", blocks, c.lexed, len(c.tokens))
+		}
+	}
+}
+
+func TestPrintJSONWithIDs(t *testing.T) {
+	ids, err := LoadIDs(strings.NewReader(`{"T_OPEN_TAG": 379, "T_VARIABLE": 317}`))
+	if err != nil {
+		t.Fatal("error loading IDs", err)
+	}
+
+	o, err := PrintJSON(Convert("<?php $a;"), ids)
+	if err != nil {
+		t.Fatal("error printing tokens", err)
+	}
+	expected := "[\n  [\n    379,\n    \"<?php \",\n    1\n  ],\n  [\n    317,\n    \"$a\",\n    1\n  ],\n  \";\"\n]\n"
+	if o != expected {
+		t.Errorf("expected %q, got %q", expected, o)
+	}
+
+	if _, err := PrintJSON(Convert("<?php 1;"), ids); err == nil || !strings.Contains(err.Error(), "T_LNUMBER") {
+		t.Errorf("expected an error for the missing ID of T_LNUMBER, got %v", err)
+	}
+	if _, err := LoadIDs(strings.NewReader(`[1]`)); err == nil {
+		t.Error("expected an error for invalid IDs")
+	}
+}
@@ -0,0 +1,12 @@
+<?php
+// Prints the result of token_get_all for a file as JSON with token names in
+// place of the numeric IDs, which is what the expected files in testdata contain:
+//
+//     php record.php testdata/class.php > testdata/class.json
+
+$tokens = [];
+foreach (token_get_all(file_get_contents($argv[1])) as $token) {
+    $tokens[] = is_array($token) ? [token_name($token[0]), $token[1], $token[2]] : $token;
+}
+
+echo json_encode($tokens, JSON_PRETTY_PRINT | JSON_UNESCAPED_SLASHES | JSON_UNESCAPED_UNICODE), "\n";
@@ -0,0 +1,21 @@
+<h1>Hi</h1>
+<?php
+// c ?> x
+<?php
+namespace App\Model;
+
+use Foo\{Bar, Baz};
+
+#[Attr]
+final class User extends \Base {
+    /** @var int */
+    private ?int $id = 0x1F;
+    # hash
+    public function get(): string {
+        $a = (int) "12" ?? 1.5e3;
+        $b = "Hi $name[0] {$this->user->name} ${x} \$no";
+        return $this?->list !== null ? "x" : namespace\f(...$args);
+    }
+}
+?>
+<p>end
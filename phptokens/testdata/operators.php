@@ -0,0 +1,9 @@
+<?php
+$i += 1; $i -= 2; $i *= 3; $i /= 4; $i .= 'a'; $i %= 5;
+$i **= 2; $i ??= 0; $i <<= 1; $i >>= 1; $i &= 1; $i |= 1; $i ^= 1;
+$j = $i <=> 2 || $i <> 3 && $i != 4 or $i xor $i and !$i;
+$k = A::B ** 2 << 1 >> 1 & 1 | 2 ^ 3 ~ 4 @ 5;
+$f = fn($x) => $x++ + --$x;
+$s = (string)$f . (Bool) $f . ( float ) 1 . (object) [];
+yield  from gen();
+echo 9223372036854775807, 9223372036854775808, 1_000, 0b101, .5, 1.;
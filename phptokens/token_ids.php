@@ -0,0 +1,15 @@
+<?php
+// Prints the IDs of all tokens of the running PHP version as JSON, to be used
+// with the -token-ids flag of "shmehashme lex -format php-tokens":
+//
+//     php token_ids.php > php-8.0-tokens.json
+
+$ids = [];
+foreach (get_defined_constants(true)['tokenizer'] as $name => $id) {
+    if (strpos($name, 'T_') === 0) {
+        $ids[$name] = $id;
+    }
+}
+ksort($ids);
+
+echo json_encode($ids, JSON_PRETTY_PRINT), "\n";
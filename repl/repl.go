@@ -4,34 +4,240 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/bestform/shmehashme/lexer"
+	"github.com/bestform/shmehashme/scan"
 )
 
-const prompt = ">> "
+const (
+	prompt             = ">> "
+	continuationPrompt = ".. "
+)
+
+// metaCommand matches lines like ":load foo.php" that control the REPL itself
+var metaCommand = regexp.MustCompile(`^\s*:([a-z]+)(\s+(.*))?$`)
+
+// mode decides how the REPL shows the result for each input
+type mode int
+
+const (
+	modeTokens mode = iota
+	modeJSON
+)
+
+// Options configure a REPL session
+type Options struct {
+	// HistoryFile is the file every entered line is appended to. Lines
+	// already in the file are available via :history. Empty disables history.
+	HistoryFile string
+}
+
+type session struct {
+	out     io.Writer
+	mode    mode
+	pending []string // lines of an input that is not complete yet
+	history []string
+	histOut io.Writer
+}
 
 // Start will launch a simple REPL that takes source code and will display the lexer result
 func Start(in io.Reader, out io.Writer) {
+	Run(in, out, Options{})
+}
+
+// Run is like Start but takes additional options. It returns when in is exhausted,
+// :quit was entered or the history file could not be used.
+//
+// Inputs are collected until all braces, parentheses and square brackets are
+// balanced and no string or block comment is left open, so classes, functions
+// and long strings can be entered over multiple lines.
+func Run(in io.Reader, out io.Writer, opts Options) error {
+	s := &session{out: out}
+
+	if opts.HistoryFile != "" {
+		f, err := s.openHistory(opts.HistoryFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		s.histOut = f
+	}
+
 	scanner := bufio.NewScanner(in)
 
 	for {
-		fmt.Print(prompt)
+		if len(s.pending) == 0 {
+			fmt.Fprint(out, prompt)
+		} else {
+			fmt.Fprint(out, continuationPrompt)
+		}
 		scanned := scanner.Scan()
 		if !scanned {
-			return
+			return scanner.Err()
 		}
 
 		line := scanner.Text()
-		reader := strings.NewReader(line)
+		s.remember(line)
+
+		if m := metaCommand.FindStringSubmatch(line); m != nil {
+			if quit := s.meta(m[1], strings.TrimSpace(m[3])); quit {
+				return nil
+			}
+			continue
+		}
 
-		l, err := lexer.New(reader)
+		s.pending = append(s.pending, line)
+		src := strings.Join(s.pending, "\n")
+		tokens, err := scan.Lex(strings.NewReader(src))
 		if err != nil {
+			return err
+		}
+		if !complete(src, tokens) {
+			continue
+		}
+
+		s.pending = nil
+		s.show(tokens)
+	}
+}
+
+func (s *session) openHistory(filename string) (*os.File, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s.history = append(s.history, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (s *session) remember(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	s.history = append(s.history, line)
+	if s.histOut != nil {
+		fmt.Fprintln(s.histOut, line)
+	}
+}
+
+// meta executes a meta command and reports whether the REPL should quit
+func (s *session) meta(command, arg string) bool {
+	switch command {
+	case "tokens":
+		s.mode = modeTokens
+	case "json":
+		s.mode = modeJSON
+	case "reset":
+		s.mode = modeTokens
+		s.pending = nil
+	case "load":
+		if arg == "" {
+			fmt.Fprintln(s.out, "Usage: :load <file>")
+			break
+		}
+		f, err := os.Open(arg)
+		if err != nil {
+			fmt.Fprintln(s.out, "Error opening file:", err)
+			break
+		}
+		tokens, err := scan.Lex(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(s.out, "Error lexing file:", err)
+			break
+		}
+		s.show(tokens)
+	case "history":
+		for i, line := range s.history {
+			fmt.Fprintf(s.out, "%5d  %s\n", i+1, line)
+		}
+	case "quit":
+		return true
+	case "help":
+		fmt.Fprintln(s.out, ":tokens       show the tokens of each input (default)")
+		fmt.Fprintln(s.out, ":json         show the tokens of each input as JSON")
+		fmt.Fprintln(s.out, ":load <file>  show the result for a file")
+		fmt.Fprintln(s.out, ":reset        discard an unfinished input and show tokens again")
+		fmt.Fprintln(s.out, ":history      list the previously entered lines")
+		fmt.Fprintln(s.out, ":quit         leave the REPL")
+	default:
+		fmt.Fprintf(s.out, "Unknown command :%s (try :help)\n", command)
+	}
+
+	return false
+}
+
+func (s *session) show(tokens []lexer.Token) {
+	switch s.mode {
+	case modeTokens:
+		for _, tok := range tokens {
+			fmt.Fprintln(s.out, tok)
+		}
+	case modeJSON:
+		o, err := lexer.PrintJSON(tokens)
+		if err != nil {
+			fmt.Fprintln(s.out, "Error encoding tokens:", err)
 			return
 		}
+		fmt.Fprint(s.out, o)
+	}
+}
+
+// complete reports whether an input can be shown: no bracket is left open
+// and the input does not end inside of a string or a block comment
+func complete(src string, tokens []lexer.Token) bool {
+	if len(tokens) > 0 && unterminated(src, tokens[len(tokens)-1]) {
+		return false
+	}
 
-		for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
-			fmt.Println(tok)
+	return balanced(tokens)
+}
+
+// unterminated reports whether the token is a string or block comment the
+// lexer ended at the end of the input instead of at its closing delimiter
+func unterminated(src string, tok lexer.Token) bool {
+	text := src[tok.Offset:tok.End]
+	switch tok.Type {
+	case lexer.DOUBLEQUOTEDSTRING, lexer.SINGLEQUOTEDSTRING:
+		for i := 1; i < len(text); i++ {
+			switch text[i] {
+			case '\\':
+				i++
+			case text[0]:
+				return false
+			}
 		}
+		return true
+	case lexer.COMMENT:
+		return strings.HasPrefix(text, "/*") && (len(text) < 4 || !strings.HasSuffix(text, "*/"))
 	}
+
+	return false
+}
+
+// balanced reports whether no brace, parenthesis or square bracket is left open
+func balanced(tokens []lexer.Token) bool {
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.Type {
+		case lexer.LBRACE, lexer.LPAREN, lexer.LSQUAREBRACKET:
+			depth++
+		case lexer.RBRACE, lexer.RPAREN, lexer.RSQUAREBRACKET:
+			depth--
+		}
+	}
+
+	return depth <= 0
 }
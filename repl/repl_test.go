@@ -0,0 +1,131 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultiLineInput(t *testing.T) {
+
+	input := "if (true) {\n" +
+		"  $a[\n" +
+		"    1];\n" +
+		"}\n"
+
+	var out bytes.Buffer
+	if err := Run(strings.NewReader(input), &out, Options{}); err != nil {
+		t.Fatal("error running REPL", err)
+	}
+
+	expected := ">> .. .. .. IF: if\nLPAREN: (\nTRUE: true\nRPAREN: )\nLBRACE: {\n" +
+		"VAR: $a\nLSQUAREBRACKET: [\nINT: 1\nRSQUAREBRACKET: ]\nSEMICOLON: ;\nRBRACE: }\n>> "
+	if out.String() != expected {
+		t.Fatalf("Output does not match expected output:\nEXPECTED:\n%s\n\nACTUAL:\n%s", expected, out.String())
+	}
+
+}
+
+func TestUnterminatedInput(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"$a = \"one\n  two\";\n", ">> .. VAR: $a\nASSIGN: =\nDOUBLEQUOTEDSTRING: one\n  two\nSEMICOLON: ;\n>> "},
+		{"'it\\'s\nhere';\n", ">> .. SINGLEQUOTEDSTRING: it's\nhere\nSEMICOLON: ;\n>> "},
+		{"/* a\n b */\n", ">> .. COMMENT:  a\n b \n>> "},
+		{"\"a\n:reset\n$b;\n", ">> .. >> VAR: $b\nSEMICOLON: ;\n>> "},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := Run(strings.NewReader(test.input), &out, Options{}); err != nil {
+			t.Fatal("error running REPL", err)
+		}
+		if out.String() != test.expected {
+			t.Errorf("%q - expected %q, got %q", test.input, test.expected, out.String())
+		}
+	}
+
+}
+
+func TestMetaCommands(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":reset\n", ">> >> "},
+		{"function foo() {\n:reset\n$a;\n", ">> .. >> VAR: $a\nSEMICOLON: ;\n>> "},
+		{":json\n:tokens\n$a;\n", ">> >> >> VAR: $a\nSEMICOLON: ;\n>> "},
+		{":json\n:reset\n$a;\n", ">> >> >> VAR: $a\nSEMICOLON: ;\n>> "},
+		{":quit\n$a;\n", ">> "},
+		{":load\n", ">> Usage: :load <file>\n>> "},
+		{":frobnicate\n", ">> Unknown command :frobnicate (try :help)\n>> "},
+		{"$a ? 1\n  : 2;\n", ">> VAR: $a\nQUESTIONMARK: ?\nINT: 1\n>> COLON: :\nINT: 2\nSEMICOLON: ;\n>> "},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		if err := Run(strings.NewReader(tt.input), &out, Options{}); err != nil {
+			t.Fatal("error running REPL", err)
+		}
+		if out.String() != tt.expected {
+			t.Fatalf("tests[%d] - output wrong. expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+
+}
+
+func TestLoad(t *testing.T) {
+
+	var out bytes.Buffer
+	err := Run(strings.NewReader(":load ../lexer/fixtures/php7.php\n"), &out, Options{})
+	if err != nil {
+		t.Fatal("error running REPL", err)
+	}
+
+	expected := ">> PHPTAG: <?php\nVAR: $a\nSPACESHIP: <=>\nVAR: $b\nSEMICOLON: ;\n>> "
+	if out.String() != expected {
+		t.Fatalf("Output does not match expected output:\nEXPECTED:\n%s\n\nACTUAL:\n%s", expected, out.String())
+	}
+
+}
+
+func TestHistory(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "repl")
+	if err != nil {
+		t.Fatal("error creating temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+	historyFile := filepath.Join(dir, "history")
+
+	opts := Options{HistoryFile: historyFile}
+	if err := Run(strings.NewReader("$a;\n\n:json\n"), ioutil.Discard, opts); err != nil {
+		t.Fatal("error running REPL", err)
+	}
+
+	var out bytes.Buffer
+	if err := Run(strings.NewReader("$b;\n:history\n"), &out, opts); err != nil {
+		t.Fatal("error running REPL", err)
+	}
+
+	expected := ">> VAR: $b\nSEMICOLON: ;\n>>     1  $a;\n    2  :json\n    3  $b;\n    4  :history\n>> "
+	if out.String() != expected {
+		t.Fatalf("Output does not match expected output:\nEXPECTED:\n%s\n\nACTUAL:\n%s", expected, out.String())
+	}
+
+	content, err := ioutil.ReadFile(historyFile)
+	if err != nil {
+		t.Fatal("error reading history file", err)
+	}
+	if string(content) != "$a;\n:json\n$b;\n:history\n" {
+		t.Fatalf("unexpected history file content %q", content)
+	}
+
+}
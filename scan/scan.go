@@ -0,0 +1,119 @@
+// Package scan finds PHP files in a directory tree and lexes them in parallel
+package scan
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+// Result holds the source code and tokens of one file, or the error that
+// occurred while reading or lexing it
+type Result struct {
+	Path   string
+	Source string
+	Tokens []lexer.Token
+	Err    error
+}
+
+// Progress is called once for every finished file. done counts the files
+// finished so far (including this one) out of total. Calls never overlap.
+type Progress func(done, total int, path string)
+
+// ProgressWriter returns a Progress that writes one line per finished file to w
+func ProgressWriter(w io.Writer) Progress {
+	return func(done, total int, path string) {
+		fmt.Fprintf(w, "[%d/%d] %s\n", done, total, path)
+	}
+}
+
+// LexFiles lexes all files using at most workers goroutines. The results are
+// returned in the same order as paths. progress may be nil.
+func LexFiles(paths []string, workers int, progress Progress) []Result {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]Result, len(paths))
+	jobs := make(chan int)
+	finished := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = lexFile(paths[i])
+				finished <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range paths {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for i := range finished {
+		done++
+		if progress != nil {
+			progress(done, len(paths), paths[i])
+		}
+	}
+
+	return results
+}
+
+func lexFile(path string) Result {
+	res := Result{Path: path}
+
+	f, err := os.Open(path)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer f.Close()
+
+	return Read(path, f)
+}
+
+// Read reads r completely and returns its source code and tokens
+func Read(path string, r io.Reader) Result {
+	res := Result{Path: path}
+
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Source = string(src)
+	res.Tokens, res.Err = Lex(strings.NewReader(res.Source))
+
+	return res
+}
+
+// Lex reads r completely and returns its tokens without the final EOF
+func Lex(r io.Reader) ([]lexer.Token, error) {
+	l, err := lexer.New(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []lexer.Token
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,196 @@
+package scan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/bestform/shmehashme/lexer"
+)
+
+func TestWalk(t *testing.T) {
+
+	tests := []struct {
+		opts     Options
+		expected []string
+	}{
+		{
+			Options{},
+			[]string{
+				"build/out.php",
+				"index.php",
+				"lib/Foo.php",
+				"lib/cache/data.php",
+				"lib/helper.inc",
+				"templates/page.phtml",
+				"vendor/acme/Acme.php",
+			},
+		},
+		{
+			Options{Extensions: []string{".php"}},
+			[]string{
+				"build/out.php",
+				"index.php",
+				"lib/Foo.php",
+				"lib/cache/data.php",
+				"vendor/acme/Acme.php",
+			},
+		},
+		{
+			Options{Exclude: []string{"vendor/", "/build", "# a comment", ""}},
+			[]string{
+				"index.php",
+				"lib/Foo.php",
+				"lib/cache/data.php",
+				"lib/helper.inc",
+				"templates/page.phtml",
+			},
+		},
+		{
+			Options{Exclude: []string{"cache", "*.inc", "templates/*.phtml"}},
+			[]string{
+				"build/out.php",
+				"index.php",
+				"lib/Foo.php",
+				"vendor/acme/Acme.php",
+			},
+		},
+		{
+			Options{Exclude: []string{"**/acme/**", "lib/**/data.php", "index.php/"}},
+			[]string{
+				"build/out.php",
+				"index.php",
+				"lib/Foo.php",
+				"lib/helper.inc",
+				"templates/page.phtml",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		files, err := Walk("testdata/project", tt.opts)
+		if err != nil {
+			t.Fatal("error walking directory", err)
+		}
+
+		var expected []string
+		for _, f := range tt.expected {
+			expected = append(expected, filepath.Join("testdata/project", f))
+		}
+		if !reflect.DeepEqual(files, expected) {
+			t.Fatalf("tests[%d] - expected %v, got %v", i, expected, files)
+		}
+	}
+
+}
+
+func TestWalkSingleFile(t *testing.T) {
+
+	files, err := Walk("testdata/project/README.md", Options{})
+	if err != nil {
+		t.Fatal("error walking file", err)
+	}
+	if !reflect.DeepEqual(files, []string{"testdata/project/README.md"}) {
+		t.Fatalf("expected the file itself, got %v", files)
+	}
+
+	if _, err := Walk("testdata/missing", Options{}); err == nil {
+		t.Fatal("expected an error for a missing path")
+	}
+
+}
+
+func TestLexFiles(t *testing.T) {
+
+	files, err := Walk("testdata/project", Options{})
+	if err != nil {
+		t.Fatal("error walking directory", err)
+	}
+	files = append(files, "testdata/project/missing.php")
+
+	for _, workers := range []int{0, 1, 3, 16} {
+		var mu sync.Mutex
+		var reported []string
+		var lastDone int
+		progress := func(done, total int, path string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if done != lastDone+1 || total != len(files) {
+				t.Errorf("unexpected progress %d/%d after %d", done, total, lastDone)
+			}
+			lastDone = done
+			reported = append(reported, path)
+		}
+
+		results := LexFiles(files, workers, progress)
+
+		if len(results) != len(files) {
+			t.Fatalf("expected %d results, got %d", len(files), len(results))
+		}
+		if len(reported) != len(files) {
+			t.Fatalf("expected %d progress reports, got %d", len(files), len(reported))
+		}
+		for i, res := range results {
+			if res.Path != files[i] {
+				t.Fatalf("results[%d] - expected path %v, got %v", i, files[i], res.Path)
+			}
+			if res.Path == "testdata/project/missing.php" {
+				if res.Err == nil {
+					t.Fatal("expected an error for a missing file")
+				}
+				continue
+			}
+			if res.Err != nil {
+				t.Fatalf("results[%d] - unexpected error %v", i, res.Err)
+			}
+			if src, _ := ioutil.ReadFile(res.Path); res.Source != string(src) {
+				t.Fatalf("results[%d] - source does not match the file content", i)
+			}
+			if len(res.Tokens) == 0 || res.Tokens[0].Type != lexer.PHPTAG {
+				t.Fatalf("results[%d] - expected tokens starting with PHPTAG, got %v", i, res.Tokens)
+			}
+		}
+	}
+
+}
+
+func TestLexFilesMatchesSequentialLexing(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		t.Fatal("error creating temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files []string
+	for i := 0; i < 50; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%02d.php", i))
+		src := fmt.Sprintf("<?php\n\nfunction f%d($a) {\n    return $a + %d;\n}\n", i, i)
+		if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+			t.Fatal("error writing file", err)
+		}
+		files = append(files, name)
+	}
+
+	results := LexFiles(files, 8, nil)
+
+	for i, res := range results {
+		f, err := os.Open(files[i])
+		if err != nil {
+			t.Fatal("error opening file", err)
+		}
+		expected, err := Lex(f)
+		f.Close()
+		if err != nil {
+			t.Fatal("error lexing file", err)
+		}
+		if !reflect.DeepEqual(res.Tokens, expected) {
+			t.Fatalf("results[%d] - parallel result differs from sequential one", i)
+		}
+	}
+
+}
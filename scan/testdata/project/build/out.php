@@ -0,0 +1,3 @@
+<?php
+
+$built = 1;
@@ -0,0 +1,4 @@
+<?php
+
+class Foo {
+}
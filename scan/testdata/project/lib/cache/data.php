@@ -0,0 +1,3 @@
+<?php
+
+$cached = 1;
@@ -0,0 +1,152 @@
+package scan
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultExtensions are the file extensions Walk looks for if none are given
+var DefaultExtensions = []string{".php", ".phtml", ".inc"}
+
+// Options control which files Walk returns
+type Options struct {
+	// Extensions of the files to return, including the dot.
+	// If empty, DefaultExtensions is used.
+	Extensions []string
+	// Exclude holds .gitignore-style patterns. A pattern without a slash
+	// matches a file or directory name at any depth, a pattern containing a
+	// slash is matched against the path relative to the root, a trailing
+	// slash only matches directories and "**" matches any number of
+	// directories. Negation with "!" is not supported.
+	Exclude []string
+}
+
+// Walk searches root recursively and returns all matching files in path order.
+// If root is a file it is returned as is, regardless of the options.
+func Walk(root string, opts Options) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = DefaultExtensions
+	}
+	var patterns []pattern
+	for _, p := range opts.Exclude {
+		if p = strings.TrimSpace(p); p != "" && !strings.HasPrefix(p, "#") {
+			patterns = append(patterns, newPattern(p))
+		}
+	}
+
+	var files []string
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		for _, pat := range patterns {
+			if pat.match(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if !info.IsDir() && hasExtension(p, extensions) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func hasExtension(p string, extensions []string) bool {
+	ext := filepath.Ext(p)
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pattern is one parsed exclude pattern
+type pattern struct {
+	segments []string
+	anchored bool // the pattern contains a slash and is matched from the root
+	dirOnly  bool // the pattern ends with a slash
+}
+
+func newPattern(p string) pattern {
+	var pat pattern
+	if strings.HasSuffix(p, "/") {
+		pat.dirOnly = true
+		p = strings.TrimSuffix(p, "/")
+	}
+	if strings.Contains(p, "/") {
+		pat.anchored = true
+		p = strings.TrimPrefix(p, "/")
+	}
+	pat.segments = strings.Split(p, "/")
+
+	return pat
+}
+
+func (p pattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], parts[len(parts)-1])
+		return ok
+	}
+
+	return matchSegments(p.segments, parts)
+}
+
+func matchSegments(segments, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+	if segments[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(segments[0], parts[0]); !ok {
+		return false
+	}
+
+	return matchSegments(segments[1:], parts[1:])
+}
@@ -0,0 +1,5 @@
+<?php
+
+IF ($a == TRUE) {  
+    Return NULL;
+}
@@ -0,0 +1,5 @@
+<?php
+
+function hello($name) {
+    return "Hello " . $name;
+}
@@ -0,0 +1,4 @@
+<?php
+
+$a = 10 % 3;
+$b = @foo();
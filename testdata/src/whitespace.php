@@ -0,0 +1,5 @@
+<?php
+
+// shmehashme-ignore trailing-whitespace
+$a = 1;   
+$b = 2;	